@@ -20,11 +20,22 @@
 package leia
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/piprate/json-gold/ld"
 	"github.com/tidwall/gjson"
@@ -34,49 +45,350 @@ import (
 // ErrNoIndex is returned when no index is found to query against
 var ErrNoIndex = errors.New("no index found")
 
+// ErrIndexNotFound is returned when an index with the given name is not part of the collection
+var ErrIndexNotFound = errors.New("index not found")
+
+// ErrIndexExists is returned when an index with the given name is already part of the collection
+var ErrIndexExists = errors.New("index already exists")
+
+// ErrMetadataNotFound is returned when the collection has no metadata for the given key
+var ErrMetadataNotFound = errors.New("metadata not found")
+
+// ErrReservedMetadataKey is returned when metadata is set with a key that is reserved for internal use
+var ErrReservedMetadataKey = errors.New("metadata key is reserved")
+
+// collectionMetadataBucket is the bucket within the collection bucket that stores the collection metadata
+const collectionMetadataBucket = "_meta"
+
+// reservedMetadataPrefix is the prefix of metadata keys that are used internally
+const reservedMetadataPrefix = "_leia."
+
+// referenceFuncMetadataKey is the metadata key that stores the fingerprint of the ReferenceFunc of the collection
+const referenceFuncMetadataKey = reservedMetadataPrefix + "referenceFunc"
+
+// referenceProbe is the document that is used to fingerprint a ReferenceFunc
+var referenceProbe = Document(`{"leia": "reference probe"}`)
+
+// ErrReferenceFuncMismatch is returned when documents are added to a collection that was created with a different ReferenceFunc
+var ErrReferenceFuncMismatch = errors.New("reference function does not match the reference function of the stored documents")
+
+// collectionTypeMetadataKey is the metadata key that stores the CollectionType of the collection
+const collectionTypeMetadataKey = reservedMetadataPrefix + "collectionType"
+
+// ErrCollectionTypeMismatch is returned when a collection is opened or written with another CollectionType than it was created with
+var ErrCollectionTypeMismatch = errors.New("collection exists with a different type")
+
+// ErrReadOnly is returned when a store opened with WithReadOnly is written to
+var ErrReadOnly = errors.New("store is read-only")
+
+// ErrStopIteration can be returned by a DocumentWalker passed to IterateFrom to stop the iteration without an error
+var ErrStopIteration = errors.New("stop iteration")
+
+// BackfillError is returned by AddIndex when one or more existing documents could not be added to a new index.
+// FailedDocuments and Causes have the same length, the cause at position i belongs to the document at position i.
+type BackfillError struct {
+	FailedDocuments []Reference
+	Causes          []error
+}
+
+func (e BackfillError) Error() string {
+	return fmt.Sprintf("failed to index %d document(s) during backfill: %v", len(e.FailedDocuments), errors.Join(e.Causes...))
+}
+
+// Unwrap returns the underlying causes so errors.Is and errors.As can be used on a BackfillError
+func (e BackfillError) Unwrap() []error {
+	return e.Causes
+}
+
+// ErrNotAnArray is returned by Collection.AddFromArray when the top-level JSON value is not an array
+var ErrNotAnArray = errors.New("document is not a JSON array")
+
+// ErrDocumentTooLarge is returned when a document exceeds the size configured with WithDocumentSizeLimit
+var ErrDocumentTooLarge = errors.New("document too large")
+
+// DocumentSizeError is returned when a document exceeds the size configured with WithDocumentSizeLimit.
+// It wraps ErrDocumentTooLarge.
+type DocumentSizeError struct {
+	Size  int
+	Limit int
+}
+
+func (e DocumentSizeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceeds the limit of %d bytes", ErrDocumentTooLarge, e.Size, e.Limit)
+}
+
+func (e DocumentSizeError) Unwrap() error {
+	return ErrDocumentTooLarge
+}
+
+// BatchError is returned by Add when the documents are added in multiple transactions and one of them failed.
+// Committed is the number of documents that were added by earlier transactions, these are not rolled back.
+type BatchError struct {
+	Committed int
+	Err       error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("failed to add documents after %d committed document(s): %v", e.Committed, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// AddResult is returned by AddTolerant. Added is the number of documents that were added.
+// Errors contains the documents that could not be added.
+type AddResult struct {
+	Added  int
+	Errors []DocumentError
+}
+
+// DocumentError describes why the document with the given Reference could not be added
+type DocumentError struct {
+	Ref Reference
+	Err error
+}
+
+func (e DocumentError) Error() string {
+	return fmt.Sprintf("failed to add document %s: %v", e.Ref.EncodeToString(), e.Err)
+}
+
+func (e DocumentError) Unwrap() error {
+	return e.Err
+}
+
 // DocumentWalker defines a function that is used as a callback for matching documents.
 // The key will be the document Reference (hash) and the value will be the raw document bytes
 type DocumentWalker func(key Reference, value []byte) error
 
-// documentCollection is the bucket that stores all the documents for a collection
+// documentCollection is the default name of the bucket that stores all the documents for a collection
 const documentCollection = "_documents"
 
-func documentCollectionByteRef() []byte {
-	return []byte(documentCollection)
+// CollectionOption is the function type for the Collection Options. They're applied when the collection is created.
+type CollectionOption func(collection *collection)
+
+// WithExactCount makes DocumentCount count the documents in the database on every call.
+// By default, the count is kept in memory after it's loaded, which is faster but misses documents added by other processes.
+func WithExactCount(exact bool) CollectionOption {
+	return func(collection *collection) {
+		collection.exactCount = exact
+	}
+}
+
+// WithDocumentBucketName overrides the name of the bucket that stores the documents of the collection.
+// The name must start with an underscore, so it can't collide with the bucket of an index, and it can't be "_meta".
+// It can't be changed for an existing collection, the documents in the old bucket are no longer found.
+func WithDocumentBucketName(name string) CollectionOption {
+	return func(collection *collection) {
+		collection.documentBucketName = name
+	}
+}
+
+// WithDocumentCompression compresses the documents of the collection with the given codec before they are stored.
+// Documents stored with another codec (or without compression) remain readable.
+func WithDocumentCompression(codec CompressionCodec) CollectionOption {
+	return func(collection *collection) {
+		collection.compression = codec
+	}
+}
+
+// defaultProgressInterval is the number of documents after which the progress function of AddIndexWithOptions is called
+const defaultProgressInterval = 1000
+
+// AddIndexOption is the function type for the options of AddIndexWithOptions and MigrateDocuments
+type AddIndexOption func(config *addIndexConfig)
+
+// addIndexConfig contains the options for adding an index
+type addIndexConfig struct {
+	progress         func(indexed, total int)
+	progressInterval int
+}
+
+// WithProgress sets a function that is called while existing documents are added to a new index,
+// with the number of documents that have been indexed and the total number of documents.
+// It's called within the transaction that builds the index, so it must not use the collection.
+// It isn't called when the collection has no documents or the index already exists.
+// For MigrateDocuments, it's called after the transaction of a batch is committed, it may then use the collection.
+func WithProgress(fn func(indexed, total int)) AddIndexOption {
+	return func(config *addIndexConfig) {
+		config.progress = fn
+	}
+}
+
+// WithProgressInterval sets the number of documents between calls to the WithProgress function, the default is 1000.
+// Values below 1 are ignored.
+func WithProgressInterval(n int) AddIndexOption {
+	return func(config *addIndexConfig) {
+		if n > 0 {
+			config.progressInterval = n
+		}
+	}
 }
 
 // Collection defines a logical collection of documents and indices within a store.
 type Collection interface {
 	// AddIndex to this collection. It doesn't matter if the index already exists.
+	// ErrIndexConflict is returned if an index with the same name but other fields has been added to this collection, see Index.SameAs.
 	// If you want to override an index (by path) drop it first.
+	// Existing documents are added to the new index. Documents that fail to be indexed are reported through a BackfillError.
+	// When the store is configured with WithStrictBackfill, the first failure rolls back the index instead.
+	// ErrIndexSchemaMismatch is returned when the stored index has the same name but a different configuration,
+	// unless the store is configured with WithAutoRebuildOnMismatch.
+	// ErrInvalidIndexName is returned for names that are empty, start with an underscore or exceed 255 bytes.
 	AddIndex(index ...Index) error
+	// AddIndexWithOptions adds a single index like AddIndex, the options configure how existing documents are indexed.
+	AddIndexWithOptions(index Index, options ...AddIndexOption) error
 	// DropIndex by path
 	DropIndex(name string) error
+	// ListIndices returns the indices added to this collection
+	ListIndices() []Index
+	// DropAllIndices removes all indices of the collection within a single transaction, including stored indices that haven't been added to this instance.
+	DropAllIndices() error
+	// ReindexAll rebuilds all indices added to this collection from the stored documents within a single transaction.
+	// Documents that fail to be indexed are reported through a BackfillError, like with AddIndex.
+	ReindexAll() error
+	// Repair removes index entries that refer to documents that aren't stored, from all stored indices within a single transaction.
+	// It returns the number of removed entries.
+	Repair() (int, error)
+	// Truncate removes all documents and index entries of the collection within a single transaction.
+	// The added indices remain registered, the metadata set with SetMetadata is removed as well.
+	Truncate() error
+	// RenameIndex renames an index without rebuilding it. The stored entries are copied to the bucket of the new name.
+	// It returns ErrIndexNotFound if the collection has no index named oldName and ErrIndexExists if newName is taken.
+	// ErrInvalidIndexName is returned if newName can't be used as index name.
+	RenameIndex(oldName, newName string) error
+	// CopyTo copies all documents to the collection with the given name in the destination store, which is created with the same CollectionType if needed.
+	// Documents are indexed by the indices that have been added to the destination collection, other indices have to be added afterwards.
+	// The copy is done in batches, each batch uses its own transaction.
+	CopyTo(dest Store, collectionName string) error
+	// MigrateDocuments calls the transformer for every document and replaces the document by the result, including its index entries.
+	// A nil result or an unchanged document is left as is. Documents are migrated in batches, each batch uses its own transaction.
+	// When the transformer returns an error, the current batch is rolled back and earlier batches remain migrated.
+	// The progress can be reported using WithProgress and WithProgressInterval.
+	MigrateDocuments(ctx context.Context, transformer func(Document) (Document, error), options ...AddIndexOption) error
 	// NewIndex creates a new index from the context of this collection
 	// If multiple field indexers are given, a compound index is created.
 	NewIndex(name string, parts ...FieldIndexer) Index
-	// Add a set of documents to this collection
-	Add(jsonSet []Document) error
-	// Get returns the data for the given key or nil if not found
-	Get(ref Reference) (Document, error)
+	// Add a set of documents to this collection within a single transaction.
+	// When the store is configured with WithMaxBatchSize, every batch uses its own transaction and a BatchError is returned if one fails.
+	// The context is checked before each document, context errors are returned and the transaction is rolled back when it has been cancelled or its deadline has exceeded.
+	// ErrReferenceFuncMismatch is returned if the collection contains documents that were added using a different ReferenceFunc.
+	// A DocumentSizeError is returned if a document exceeds the size configured with WithDocumentSizeLimit.
+	Add(ctx context.Context, jsonSet []Document) error
+	// AddFromArray adds every element of the top-level JSON array as a separate document, like Add.
+	// ErrInvalidJSON is returned for invalid JSON and ErrNotAnArray if the top-level value is not an array.
+	AddFromArray(ctx context.Context, arrayDoc Document) error
+	// AddBatch adds a set of documents to this collection, like Add without a context.
+	AddBatch(jsonSet []Document) error
+	// AddInBatches adds the documents using a transaction per batchSize documents and returns the number of committed documents.
+	// When a batch fails, the documents of earlier batches remain added. When the context is done, it stops after the current batch.
+	AddInBatches(ctx context.Context, docs []Document, batchSize int) (int, error)
+	// AddConcurrent adds a set of documents to this collection, like Add.
+	// Concurrent calls are combined into a single transaction, which improves throughput when many goroutines add documents.
+	// A single call may take a bit longer since it waits for other calls to join the transaction.
+	AddConcurrent(jsonSet []Document) error
+	// AddTolerant adds every document in its own transaction, so a document that can't be indexed doesn't prevent the others from being added.
+	// Documents that failed are reported in AddResult.Errors. The error is only returned for database errors and context errors,
+	// AddResult then contains the documents processed so far.
+	AddTolerant(ctx context.Context, docs []Document) (AddResult, error)
+	// AddOrUpdate adds the document or replaces the stored document with the same reference, within a single transaction.
+	// The index entries of the replaced document are removed. This is only useful with a ReferenceFunc that doesn't hash the whole document.
+	AddOrUpdate(doc Document) error
+	// Get returns the data for the given key.
+	// found is false if the document doesn't exist, err is only returned for storage failures.
+	Get(ref Reference) (doc Document, found bool, err error)
+	// Exists returns true if a document with the given reference is stored, without reading the document.
+	Exists(ref Reference) (bool, error)
+	// GetOrAdd returns the stored document with the same reference as the given document.
+	// If it doesn't exist, the document is added and created is true. Both are done within a single transaction.
+	GetOrAdd(doc Document) (existing Document, created bool, err error)
+	// FindByReference returns the documents for the given references, fetched within a single transaction.
+	// Duplicate references are only fetched once and the result is in the order of the references.
+	// Missing documents are omitted, unless the store is configured with WithMissingReferencePlaceholders.
+	// Then a nil Document is returned in their place.
+	FindByReference(refs ...Reference) ([]Document, error)
+	// GetMulti returns the documents for the given references, fetched within a single transaction.
+	// The map is keyed by string(ref), missing documents are absent.
+	GetMulti(refs []Reference) (map[string]Document, error)
 	// Delete a document
 	Delete(doc Document) error
+	// DeleteWhere deletes the documents that match the query within a single transaction and returns the number of deleted documents.
+	// The entries of the documents are removed from all indices, not only from the index used by the query.
+	// returns context errors when the context has been cancelled or deadline has exceeded, nothing is deleted then.
+	DeleteWhere(ctx context.Context, query Query) (int, error)
 	// Find queries the collection for documents
 	// returns ErrNoIndex when no suitable index can be found
 	// returns context errors when the context has been cancelled or deadline has exceeded.
 	// passing ctx prevents adding too many records to the result set.
 	Find(ctx context.Context, query Query) ([]Document, error)
+	// SortedFind queries the collection like Find, but returns the documents ordered by the value at the orderBy path.
+	// When the first field of the index used for the query has the orderBy path, ascending results are returned in index order without sorting.
+	// Otherwise, the results are sorted by their smallest value at the path, compared like index keys: as bytes, after the Transform of an indexed field with the same path.
+	// Documents without a value at the path are returned last. The limit and skip of the query are applied to the ordered results.
+	SortedFind(ctx context.Context, query Query, orderBy QueryPath, ascending bool) ([]Document, error)
+	// Count returns the number of documents that match the query.
+	// When the query is fully covered by an index, only the index is scanned and no documents are loaded.
+	// The limit and skip of the query are ignored.
+	// returns context errors when the context has been cancelled or deadline has exceeded.
+	Count(ctx context.Context, query Query) (int, error)
+	// Distinct returns the unique values at the given path, sorted by their bytes.
+	// When an index with a single field on the path exists, the values are read from the index and are transformed and tokenized like its keys.
+	// Otherwise, all documents are scanned and the values are returned as they are in the documents.
+	// returns context errors when the context has been cancelled or deadline has exceeded.
+	Distinct(ctx context.Context, path QueryPath) ([]Scalar, error)
 	// Reference uses the configured reference function to generate a reference of the function
 	Reference(doc Document) Reference
 	// Iterate over documents that match the given query
 	Iterate(query Query, walker DocumentWalker) error
+	// ExplainQuery describes how the query would be executed: a full table scan or the chosen index and its score,
+	// and which query parts are resolved by the index. The query isn't executed.
+	ExplainQuery(query Query) (string, error)
+	// IterateFrom calls the walker for every document that matches the query, in order of reference, starting after the bookmark.
+	// Pass a nil bookmark to start at the beginning. The returned bookmark is the reference of the last processed document,
+	// it can be stored and passed to a later call to resume the iteration, e.g. after a restart.
+	// The walker may return ErrStopIteration to stop without an error, the current document counts as processed.
+	// Indices are not used, since the order of an index can't be resumed reliably when documents are added or removed.
+	IterateFrom(query Query, bookmark []byte, walker DocumentWalker) ([]byte, error)
+	// WalkDocuments calls the DocumentWalker for every document in the collection, like ForEach.
+	WalkDocuments(ctx context.Context, fn DocumentWalker) error
+	// ForEach calls the DocumentWalker for every document in the collection in order of reference, without using a query or index.
+	// The context is checked before each call, context errors are returned when it has been cancelled or its deadline has exceeded.
+	ForEach(ctx context.Context, walker DocumentWalker) error
 	// IndexIterate is used for iterating over indexed values. The query keys must match exactly with all the FieldIndexer.Name() of an index
 	// returns ErrNoIndex when no suitable index can be found
-	IndexIterate(query Query, fn ReferenceScanFn) error
+	// returns context errors when the context has been cancelled or deadline has exceeded.
+	IndexIterate(ctx context.Context, query Query, fn ReferenceScanFn) error
 	// ValuesAtPath returns a slice with the values found by the configured valueCollector
 	ValuesAtPath(document Document, queryPath QueryPath) ([]Scalar, error)
-	// DocumentCount returns the number of indexed documents
+	// SetDocumentLoader overrides the document loader of the store for this collection.
+	// It's only used by JSON-LD collections. It must be called before AddIndex, since the loader is used to index existing documents.
+	SetDocumentLoader(loader ld.DocumentLoader)
+	// DocumentLoader returns the document loader used by this collection
+	DocumentLoader() ld.DocumentLoader
+	// SetMetadata stores a key-value pair for the collection, like a schema version or the time of the last sync.
+	// Keys starting with "_leia." are reserved, ErrReservedMetadataKey is returned for those.
+	SetMetadata(key, value string) error
+	// GetMetadata returns the value for the given key, or ErrMetadataNotFound if it isn't set.
+	GetMetadata(key string) (string, error)
+	// AllMetadata returns all key-value pairs set with SetMetadata
+	AllMetadata() (map[string]string, error)
+	// DocumentCount returns the number of indexed documents.
+	// The count is loaded from the database on the first call and kept in memory after that, unless WithExactCount is used.
+	// Use Count to count the documents matching a query.
 	DocumentCount() (int, error)
+	// AddHook registers a function that is called after an operation of the given EventType has completed successfully.
+	// Hooks are called synchronously, so they should return quickly.
+	AddHook(event EventType, fn func(EventData))
+	// RemoveHooks removes all hooks for the given EventType
+	RemoveHooks(event EventType)
+	// Watch returns a channel that receives a DocumentEvent for each document that is added to or deleted from this collection.
+	// Events are sent after the transaction has been committed, writers block when the channel buffer is full.
+	// The channel is closed when the context is done.
+	Watch(ctx context.Context) <-chan DocumentEvent
+	// CollectionStats returns a snapshot of the read statistics of this collection
+	CollectionStats() CollectionStats
+	// ResetStats sets all read statistics of this collection to zero
+	ResetStats()
 }
 
 // ReferenceFunc is the func type used for creating references.
@@ -85,23 +397,57 @@ type Collection interface {
 // The first would be best for random access, the latter for chronological access
 type ReferenceFunc func(doc Document) Reference
 
-// default for shasum docs
-func defaultReferenceCreator(doc Document) Reference {
-	s := sha1.Sum(doc)
-	var b = make([]byte, len(s))
-	copy(b, s[:])
+// SHA1ReferenceCreator creates references using SHA-1. This is the default.
+var SHA1ReferenceCreator = hashReferenceCreator(sha1.New)
 
-	return b
+// SHA256ReferenceCreator creates references using SHA-256
+var SHA256ReferenceCreator = hashReferenceCreator(sha256.New)
+
+// default for shasum docs
+var defaultReferenceCreator = SHA1ReferenceCreator
+
+// hashReferenceCreator returns a ReferenceFunc that uses a new hash.Hash from newHash for every document
+func hashReferenceCreator(newHash func() hash.Hash) ReferenceFunc {
+	return func(doc Document) Reference {
+		h := newHash()
+		// hash.Hash never returns an error on Write
+		_, _ = h.Write(doc)
+		return h.Sum(nil)
+	}
 }
 
 type collection struct {
-	name           string
-	db             *bbolt.DB
-	indexList      []Index
-	refMake        ReferenceFunc
-	documentLoader ld.DocumentLoader
-	collectionType CollectionType
-	valueCollector valueCollector
+	name      string
+	db        *boltDB
+	indexList []Index
+	// indexLock protects indexList. It must be acquired before a bbolt transaction is started.
+	indexLock           sync.RWMutex
+	refMake             ReferenceFunc
+	documentLoader      ld.DocumentLoader
+	collectionType      CollectionType
+	documentBucketName  string
+	valueCollector      valueCollector
+	strictBackfill      bool
+	autoRebuild         bool
+	missingPlaceholders bool
+	compression         CompressionCodec
+	// maxBatchSize is the maximum number of documents added per transaction by Add, 0 means no maximum
+	maxBatchSize int
+	// maxDocumentSize is the maximum size of a document in bytes, 0 means no maximum
+	maxDocumentSize int
+	// exactCount disables the in-memory document count
+	exactCount bool
+	// logger is configured by WithLogger, use log() since it may be nil
+	logger *slog.Logger
+	// readOnly causes write transactions to fail with ErrReadOnly
+	readOnly bool
+	// docCount is the in-memory document count, it's loaded on the first call to DocumentCount.
+	// docCountLoaded is protected by indexLock.
+	docCount       atomic.Int64
+	docCountLoaded bool
+	stats          collectionStats
+	hooks          hooks
+	watchers       watchers
 }
 
 func (c *collection) NewIndex(name string, parts ...FieldIndexer) Index {
@@ -113,47 +459,159 @@ func (c *collection) NewIndex(name string, parts ...FieldIndexer) Index {
 }
 
 func (c *collection) AddIndex(indexes ...Index) error {
+	return c.addIndices(indexes, addIndexConfig{})
+}
+
+func (c *collection) AddIndexWithOptions(index Index, options ...AddIndexOption) error {
+	config := addIndexConfig{progressInterval: defaultProgressInterval}
+	for _, option := range options {
+		option(&config)
+	}
+	return c.addIndices([]Index{index}, config)
+}
+
+func (c *collection) addIndices(indexes []Index, config addIndexConfig) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	var backfillErr BackfillError
+
 	for _, index := range indexes {
 		for _, i := range c.indexList {
 			if i.Name() == index.Name() {
+				if !i.SameAs(index) {
+					return fmt.Errorf("%w: %s", ErrIndexConflict, index.Name())
+				}
 				return nil
 			}
 		}
+		if err := validateIndexName(index.Name()); err != nil {
+			return err
+		}
 
-		if err := c.db.Update(func(tx *bbolt.Tx) error {
-			bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
-			if err != nil {
+		if c.readOnly {
+			// a stored index can be used, but it can't be built
+			if err := c.checkStoredIndex(index); err != nil {
 				return err
 			}
-
-			// skip existing
-			if b := bucket.Bucket(index.BucketName()); b != nil {
-				return nil
-			}
-
-			gBucket, err := bucket.CreateBucketIfNotExists(documentCollectionByteRef())
+		} else if err := c.update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
 			if err != nil {
 				return err
 			}
 
-			cur := gBucket.Cursor()
-			for ref, doc := cur.First(); ref != nil; ref, doc = cur.Next() {
-				index.Add(bucket, ref, doc)
+			// skip existing, unless it was created with a different configuration
+			if bucket.Bucket(index.BucketName()) != nil {
+				match, err := indexMetadataMatches(bucket, index)
+				if err != nil {
+					return err
+				}
+				if match {
+					return nil
+				}
+				if !c.autoRebuild {
+					return fmt.Errorf("%w: %s", ErrIndexSchemaMismatch, index.Name())
+				}
+				if err = bucket.DeleteBucket(index.BucketName()); err != nil {
+					return err
+				}
 			}
 
-			return nil
+			return c.buildIndex(bucket, index, &backfillErr, config)
 		}); err != nil {
 			return err
 		}
 
 		c.indexList = append(c.indexList, index)
+		c.log().Info("index added", "index", index.Name())
+	}
+
+	if len(backfillErr.FailedDocuments) > 0 {
+		return backfillErr
+	}
+
+	return nil
+}
+
+// buildIndex creates the bucket of the index and adds all existing documents to it.
+// Documents that fail to be indexed are added to backfillErr, unless strict backfill is configured.
+// The progress function of the config is called every progressInterval documents and after the last document.
+func (c *collection) buildIndex(bucket *bbolt.Bucket, index Index, backfillErr *BackfillError, config addIndexConfig) error {
+	if _, err := bucket.CreateBucket(index.BucketName()); err != nil {
+		return err
+	}
+	if err := putIndexMetadata(bucket, index, time.Now()); err != nil {
+		return err
+	}
+
+	gBucket, err := bucket.CreateBucketIfNotExists(c.documentCollectionByteRef())
+	if err != nil {
+		return err
+	}
+
+	total, indexed := 0, 0
+	if config.progress != nil {
+		total = gBucket.Stats().KeyN
+	}
+
+	cur := gBucket.Cursor()
+	for ref, data := cur.First(); ref != nil; ref, data = cur.Next() {
+		doc, err := decodeDocument(data)
+		if err == nil {
+			err = index.Add(bucket, ref, doc)
+		}
+		if err != nil {
+			if c.strictBackfill {
+				return fmt.Errorf("failed to index document %s: %w", Reference(ref).EncodeToString(), err)
+			}
+			// copy the ref, it's only valid during the transaction
+			failedRef := make(Reference, len(ref))
+			copy(failedRef, ref)
+			backfillErr.FailedDocuments = append(backfillErr.FailedDocuments, failedRef)
+			backfillErr.Causes = append(backfillErr.Causes, err)
+		}
+		indexed++
+		if config.progress != nil && indexed%config.progressInterval == 0 {
+			config.progress(indexed, total)
+		}
+	}
+	if config.progress != nil && indexed%config.progressInterval != 0 {
+		config.progress(indexed, total)
 	}
 
 	return nil
 }
 
+// checkStoredIndex returns ErrReadOnly if the index isn't stored and ErrIndexSchemaMismatch if it's stored with a different configuration.
+func (c *collection) checkStoredIndex(index Index) error {
+	return c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return ErrReadOnly
+		}
+		if bucket.Bucket(index.BucketName()) == nil {
+			return ErrReadOnly
+		}
+		// indices built before the metadata was stored are accepted, like they are in a writable store
+		if getIndexMetadata(bucket, index.BucketName()) == nil {
+			return nil
+		}
+		match, err := indexMetadataMatches(bucket, index)
+		if err != nil {
+			return err
+		}
+		if !match {
+			return fmt.Errorf("%w: %s", ErrIndexSchemaMismatch, index.Name())
+		}
+		return nil
+	})
+}
+
 func (c *collection) DropIndex(name string) error {
-	return c.db.Update(func(tx *bbolt.Tx) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
 		if err != nil {
 			return err
@@ -164,6 +622,10 @@ func (c *collection) DropIndex(name string) error {
 		for _, i := range c.indexList {
 			if name == i.Name() {
 				bucket.DeleteBucket(i.BucketName())
+				if err = deleteIndexMetadata(bucket, i.BucketName()); err != nil {
+					return err
+				}
+				c.log().Info("index dropped", "index", name)
 			} else {
 				newIndices[j] = i
 				j++
@@ -174,70 +636,1002 @@ func (c *collection) DropIndex(name string) error {
 	})
 }
 
-func (c *collection) Reference(doc Document) Reference {
-	return c.refMake(doc)
+func (c *collection) ListIndices() []Index {
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	indices := make([]Index, len(c.indexList))
+	copy(indices, c.indexList)
+	return indices
 }
 
-// Add a json document set to the store
-// this uses a single transaction per set.
-func (c *collection) Add(jsonSet []Document) error {
-	return c.db.Update(func(tx *bbolt.Tx) error {
-		return c.add(tx, jsonSet)
+func (c *collection) DropAllIndices() error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	err := c.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		// collect the names first, buckets can't be deleted while iterating
+		var names [][]byte
+		err := bucket.ForEachBucket(func(name []byte) error {
+			if !strings.HasPrefix(string(name), "_") {
+				names = append(names, append([]byte{}, name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err = bucket.DeleteBucket(name); err != nil {
+				return err
+			}
+			if err = deleteIndexMetadata(bucket, name); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	c.indexList = nil
+	c.log().Info("all indices dropped")
+	return nil
 }
 
-func (c *collection) add(tx *bbolt.Tx, jsonSet []Document) error {
-	bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
+func (c *collection) ReindexAll() error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	var backfillErr BackfillError
+	err := c.update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
+		if err != nil {
+			return err
+		}
+		for _, index := range c.indexList {
+			if bucket.Bucket(index.BucketName()) != nil {
+				if err = bucket.DeleteBucket(index.BucketName()); err != nil {
+					return err
+				}
+			}
+			if err = c.buildIndex(bucket, index, &backfillErr, addIndexConfig{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	docBucket, err := bucket.CreateBucketIfNotExists(documentCollectionByteRef())
+	if len(backfillErr.FailedDocuments) > 0 {
+		return backfillErr
+	}
+	return nil
+}
+
+func (c *collection) Repair() (int, error) {
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	removed := 0
+	err := c.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		docBucket := bucket.Bucket(c.documentCollectionByteRef())
+		// collect the names first, buckets can't be modified while iterating
+		var names [][]byte
+		err := bucket.ForEachBucket(func(name []byte) error {
+			if !strings.HasPrefix(string(name), "_") {
+				names = append(names, append([]byte{}, name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			count, err := removeOrphans(bucket.Bucket(name), docBucket)
+			if err != nil {
+				return err
+			}
+			removed += count
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// removeOrphans removes the references from the index bucket that aren't in the document bucket.
+// Keys without references are removed as well. It returns the number of removed references.
+func removeOrphans(iBucket *bbolt.Bucket, docBucket *bbolt.Bucket) (int, error) {
+	// buckets can't be modified while iterating, so the keys and references are collected first
+	var keys [][]byte
+	err := iBucket.ForEachBucket(func(key []byte) error {
+		keys = append(keys, append([]byte{}, key...))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, key := range keys {
+		refs := iBucket.Bucket(key)
+		var orphans [][]byte
+		err = refs.ForEach(func(ref, _ []byte) error {
+			if docBucket == nil || docBucket.Get(ref) == nil {
+				orphans = append(orphans, append([]byte{}, ref...))
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, ref := range orphans {
+			if err = refs.Delete(ref); err != nil {
+				return 0, err
+			}
+		}
+		removed += len(orphans)
+		if k, _ := refs.Cursor().First(); k == nil {
+			if err = iBucket.DeleteBucket(key); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+func (c *collection) Truncate() error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	err := c.update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(c.name)) != nil {
+			if err := tx.DeleteBucket([]byte(c.name)); err != nil {
+				return err
+			}
+		}
+		bucket, err := tx.CreateBucket([]byte(c.name))
+		if err != nil {
+			return err
+		}
+		if _, err = bucket.CreateBucket(c.documentCollectionByteRef()); err != nil {
+			return err
+		}
+		// there are no documents, so building an index only creates its bucket
+		var backfillErr BackfillError
+		for _, index := range c.indexList {
+			if err = c.buildIndex(bucket, index, &backfillErr, addIndexConfig{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	for _, doc := range jsonSet {
-		ref := c.refMake(doc)
+	c.docCount.Store(0)
+	c.docCountLoaded = true
+	return nil
+}
 
-		// indices
-		// buckets are cached within tx
-		for _, i := range c.indexList {
-			err = i.Add(bucket, ref, doc)
-			if err != nil {
+func (c *collection) RenameIndex(oldName, newName string) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	if err := validateIndexName(newName); err != nil {
+		return err
+	}
+	position := -1
+	for j, i := range c.indexList {
+		if i.Name() == newName {
+			return fmt.Errorf("%w: %s", ErrIndexExists, newName)
+		}
+		if i.Name() == oldName {
+			position = j
+		}
+	}
+	if position == -1 {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, oldName)
+	}
+	current, ok := c.indexList[position].(*index)
+	if !ok {
+		return fmt.Errorf("index %s can't be renamed: not created by this collection", oldName)
+	}
+	renamed := &index{
+		name:       newName,
+		indexParts: current.indexParts,
+		collection: current.collection,
+	}
+
+	err := c.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		if bucket.Bucket(renamed.BucketName()) != nil {
+			return fmt.Errorf("%w: %s", ErrIndexExists, newName)
+		}
+		src := bucket.Bucket(current.BucketName())
+		if src == nil {
+			return nil
+		}
+		dst, err := bucket.CreateBucket(renamed.BucketName())
+		if err != nil {
+			return err
+		}
+		if err = copyBucket(dst, src); err != nil {
+			return err
+		}
+		if metadata := getIndexMetadata(bucket, current.BucketName()); metadata != nil {
+			// the metadata is keyed by the bucket name, the hash doesn't include the name
+			if err = bucket.Bucket([]byte(collectionMetadataBucket)).Put(indexMetadataKey(renamed.BucketName()), append([]byte{}, metadata...)); err != nil {
+				return err
+			}
+			if err = deleteIndexMetadata(bucket, current.BucketName()); err != nil {
 				return err
 			}
 		}
-
-		err = docBucket.Put(ref, doc)
+		return bucket.DeleteBucket(current.BucketName())
+	})
+	if err != nil {
+		return err
+	}
+
+	c.indexList[position] = renamed
+	return nil
+}
+
+func (c *collection) CopyTo(dest Store, collectionName string) error {
+	if s, ok := dest.(*store); ok && s.db == c.db && collectionName == c.name {
+		return errors.New("source and destination collection must differ")
+	}
+	dst, err := dest.OpenCollection(c.collectionType, collectionName)
+	if err != nil {
+		return err
+	}
+	_, err = copyDocuments(context.Background(), c.db, c.name, c.documentCollectionByteRef(), dst)
+	return err
+}
+
+func (c *collection) MigrateDocuments(ctx context.Context, transformer func(Document) (Document, error), options ...AddIndexOption) error {
+	config := addIndexConfig{progressInterval: defaultProgressInterval}
+	for _, option := range options {
+		option(&config)
+	}
+
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	// the references are collected first, so migrated documents with a new reference aren't migrated twice
+	var refs []Reference
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := c.documentBucket(tx)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(ref, _ []byte) error {
+			refs = append(refs, append(Reference{}, ref...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(refs); start += copyBatchSize {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+copyBatchSize, len(refs))
+		var replaced, added []Document
+		err = c.update(func(tx *bbolt.Tx) error {
+			replaced, added = nil, nil
+			docBucket := c.documentBucket(tx)
+			for _, ref := range refs[start:end] {
+				stored := docBucket.Get(ref)
+				if stored == nil {
+					// deleted in the meantime
+					continue
+				}
+				data, err := decodeDocument(stored)
+				if err != nil {
+					return err
+				}
+				// copy the data, it's only valid until the document is deleted
+				doc := append(Document{}, data...)
+				migrated, err := transformer(doc)
+				if err != nil {
+					return fmt.Errorf("failed to migrate document %s: %w", ref.EncodeToString(), err)
+				}
+				if migrated == nil || bytes.Equal(migrated, doc) {
+					continue
+				}
+				if _, err = c.deleteRef(tx, ref, doc); err != nil {
+					return err
+				}
+				newDocs, err := c.add(ctx, tx, []Document{migrated})
+				if err != nil {
+					return err
+				}
+				replaced = append(replaced, doc)
+				added = append(added, newDocs...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		c.updateCount(len(added) - len(replaced))
+		pending.add(OpDelete, replaced...)
+		pending.add(OpAdd, added...)
+		if config.progress != nil && (end/config.progressInterval > start/config.progressInterval || end == len(refs)) {
+			config.progress(end, len(refs))
+		}
+	}
+	return nil
+}
+
+// copyBucket copies all keys and nested buckets of src to dst
+func copyBucket(dst *bbolt.Bucket, src *bbolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		subDst, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(subDst, src.Bucket(k))
+	})
+}
+
+func (c *collection) Reference(doc Document) Reference {
+	return c.refMake(doc)
+}
+
+// Add a json document set to the store
+// this uses a single transaction per set.
+func (c *collection) Add(ctx context.Context, jsonSet []Document) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	if c.maxBatchSize <= 0 || len(jsonSet) <= c.maxBatchSize {
+		return c.addTx(ctx, jsonSet, &pending)
+	}
+	committed := 0
+	for committed < len(jsonSet) {
+		end := min(committed+c.maxBatchSize, len(jsonSet))
+		if err := c.addTx(ctx, jsonSet[committed:end], &pending); err != nil {
+			return BatchError{Committed: committed, Err: err}
+		}
+		committed = end
+	}
+	return nil
+}
+
+// addTx adds the documents within a single transaction and queues their events. The caller must hold the read lock on indexLock.
+func (c *collection) addTx(ctx context.Context, jsonSet []Document, pending *pendingEvents) error {
+	start := time.Now()
+	var added []Document
+	err := c.update(func(tx *bbolt.Tx) (err error) {
+		added, err = c.add(ctx, tx, jsonSet)
+		return err
+	})
+	if err == nil {
+		c.updateCount(len(added))
+		c.emit(EventAdd, start, nil, len(added))
+		pending.add(OpAdd, added...)
+	}
+	return err
+}
+
+func (c *collection) AddFromArray(ctx context.Context, arrayDoc Document) error {
+	if !gjson.ValidBytes(arrayDoc) {
+		return ErrInvalidJSON
+	}
+	result := gjson.ParseBytes(arrayDoc)
+	if !result.IsArray() {
+		return ErrNotAnArray
+	}
+	var docs []Document
+	result.ForEach(func(_, element gjson.Result) bool {
+		docs = append(docs, Document(element.Raw))
+		return true
+	})
+	return c.Add(ctx, docs)
+}
+
+func (c *collection) AddBatch(jsonSet []Document) error {
+	return c.Add(context.Background(), jsonSet)
+}
+
+func (c *collection) AddInBatches(ctx context.Context, docs []Document, batchSize int) (int, error) {
+	if batchSize < 1 {
+		return 0, errors.New("batch size must be at least 1")
+	}
+
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	committed := 0
+	for committed < len(docs) {
+		if err := ctx.Err(); err != nil {
+			return committed, err
+		}
+		end := min(committed+batchSize, len(docs))
+		// the context isn't passed, so a started batch is completed
+		if err := c.addTx(context.Background(), docs[committed:end], &pending); err != nil {
+			return committed, err
+		}
+		committed = end
+	}
+	return committed, nil
+}
+
+// AddConcurrent adds a json document set to the store using bbolt's DB.Batch.
+// If the combined transaction fails, bbolt retries each set in its own transaction, so a failing set doesn't affect other callers.
+func (c *collection) AddConcurrent(jsonSet []Document) error {
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	start := time.Now()
+	var added []Document
+	// the function may be called more than once, so the added documents are assigned instead of appended
+	err := c.batch(func(tx *bbolt.Tx) (err error) {
+		added, err = c.add(context.Background(), tx, jsonSet)
+		return err
+	})
+	if err == nil {
+		c.updateCount(len(added))
+		c.emit(EventAdd, start, nil, len(added))
+		pending.add(OpAdd, added...)
+	}
+	return err
+}
+
+func (c *collection) AddTolerant(ctx context.Context, docs []Document) (AddResult, error) {
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	var result AddResult
+	start := time.Now()
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var docErr error
+		var added []Document
+		err := c.update(func(tx *bbolt.Tx) error {
+			added, docErr = c.add(ctx, tx, []Document{doc})
+			return docErr
+		})
+		if err != nil && (docErr == nil || errors.Is(docErr, ErrReferenceFuncMismatch) || errors.Is(docErr, ErrCollectionTypeMismatch)) {
+			// the transaction itself failed or no document can be added
+			return result, err
+		}
+		if docErr != nil {
+			result.Errors = append(result.Errors, DocumentError{Ref: c.refMake(doc), Err: docErr})
+			continue
+		}
+		c.updateCount(len(added))
+		pending.add(OpAdd, added...)
+		result.Added++
+	}
+
+	if result.Added > 0 {
+		c.emit(EventAdd, start, nil, result.Added)
+	}
+	return result, nil
+}
+
+func (c *collection) AddOrUpdate(doc Document) error {
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	ref := c.refMake(doc)
+	var replaced Document
+
+	start := time.Now()
+	err := c.update(func(tx *bbolt.Tx) error {
+		if bucket := c.documentBucket(tx); bucket != nil {
+			if stored := bucket.Get(ref); stored != nil {
+				data, err := decodeDocument(stored)
+				if err != nil {
+					return err
+				}
+				// copy the data, it's only valid until the document is deleted
+				replaced = append(Document{}, data...)
+				if _, err = c.deleteRef(tx, ref, replaced); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := c.add(context.Background(), tx, []Document{doc})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if replaced != nil {
+		pending.add(OpDelete, replaced)
+	} else {
+		c.updateCount(1)
+	}
+	c.emit(EventAdd, start, nil, 1)
+	pending.add(OpAdd, doc)
+	return nil
+}
+
+// add stores the documents within the given transaction and returns the documents that weren't stored before.
+// It stops with the context error when the context is done.
+func (c *collection) add(ctx context.Context, tx *bbolt.Tx, jsonSet []Document) ([]Document, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
+	if err != nil {
+		return nil, err
+	}
+
+	docBucket, err := bucket.CreateBucketIfNotExists(c.documentCollectionByteRef())
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkReferenceFunc(bucket); err != nil {
+		return nil, err
+	}
+	if err = c.checkCollectionType(bucket); err != nil {
+		return nil, err
+	}
+
+	added := make([]Document, 0, len(jsonSet))
+	for _, doc := range jsonSet {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if c.maxDocumentSize > 0 && len(doc) > c.maxDocumentSize {
+			return nil, DocumentSizeError{Size: len(doc), Limit: c.maxDocumentSize}
+		}
+		ref := c.refMake(doc)
+
+		// indices
+		// buckets are cached within tx
+		for _, i := range c.indexList {
+			err = i.Add(bucket, ref, doc)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := encodeDocument(c.compression, doc)
+		if err != nil {
+			return nil, err
+		}
+		if docBucket.Get(ref) == nil {
+			added = append(added, doc)
+		}
+		err = docBucket.Put(ref, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return added, nil
+}
+
+// checkCollectionType compares the CollectionType with the one that is stored in the collection metadata.
+// The type is stored when it's missing.
+func (c *collection) checkCollectionType(bucket *bbolt.Bucket) error {
+	metaBucket, err := bucket.CreateBucketIfNotExists([]byte(collectionMetadataBucket))
+	if err != nil {
+		return err
+	}
+	stored := metaBucket.Get([]byte(collectionTypeMetadataKey))
+	if stored == nil {
+		return metaBucket.Put([]byte(collectionTypeMetadataKey), []byte(c.collectionType.String()))
+	}
+	if string(stored) != c.collectionType.String() {
+		return fmt.Errorf("%w: %s is a %s collection", ErrCollectionTypeMismatch, c.name, stored)
+	}
+	return nil
+}
+
+// checkReferenceFunc compares the fingerprint of the ReferenceFunc with the one that is stored in the collection metadata.
+// The fingerprint is stored when it's missing. ReferenceFuncs that don't return the same reference twice can't be checked.
+func (c *collection) checkReferenceFunc(bucket *bbolt.Bucket) error {
+	fingerprint := c.refMake(referenceProbe)
+	if !bytes.Equal(fingerprint, c.refMake(referenceProbe)) {
+		return nil
+	}
+	metaBucket, err := bucket.CreateBucketIfNotExists([]byte(collectionMetadataBucket))
+	if err != nil {
+		return err
+	}
+	stored := metaBucket.Get([]byte(referenceFuncMetadataKey))
+	if stored == nil {
+		return metaBucket.Put([]byte(referenceFuncMetadataKey), fingerprint)
+	}
+	if !bytes.Equal(stored, fingerprint) {
+		return ErrReferenceFuncMismatch
+	}
+	return nil
+}
+
+func (c *collection) Find(ctx context.Context, query Query) ([]Document, error) {
+	c.stats.find.Add(1)
+	start := time.Now()
+	docs := make([]Document, 0)
+	walker := func(key Reference, value []byte) error {
+		// stop iteration when needed
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		docs = append(docs, value)
+		return nil
+	}
+
+	if err := c.iterate(query, walker); err != nil {
+		return nil, err
+	}
+
+	c.emit(EventFind, start, nil, len(docs))
+	return docs, nil
+}
+
+func (c *collection) SortedFind(ctx context.Context, query Query, orderBy QueryPath, ascending bool) ([]Document, error) {
+	c.stats.find.Add(1)
+	start := time.Now()
+
+	plan, err := c.queryPlan(query)
+	if err != nil {
+		return nil, err
+	}
+	// when the plan doesn't return the documents in the requested order, paging is applied after sorting
+	sorted := planOrderedBy(plan, orderBy) && ascending
+	if !sorted {
+		unpaged := query
+		unpaged.limit = 0
+		unpaged.skip = 0
+		if plan, err = c.queryPlan(unpaged); err != nil {
+			return nil, err
+		}
+	}
+
+	refs := make([]Reference, 0)
+	docs := make(map[string]Document)
+	err = plan.execute(func(key Reference, value []byte) error {
+		// stop iteration when needed
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// copy the ref, it's only valid during the transaction
+		ref := append(Reference{}, key...)
+		refs = append(refs, ref)
+		docs[string(ref)] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sorted {
+		result := make([]Document, len(refs))
+		for j, ref := range refs {
+			result[j] = docs[string(ref)]
+		}
+		c.emit(EventFind, start, nil, len(result))
+		return result, nil
+	}
+
+	if err = c.sortReferences(ctx, refs, docs, orderBy, ascending); err != nil {
+		return nil, err
+	}
+	result := make([]Document, 0)
+	for j := query.skip; j < len(refs); j++ {
+		if query.limit > 0 && len(result) >= query.limit {
+			break
+		}
+		result = append(result, docs[string(refs[j])])
+	}
+
+	c.emit(EventFind, start, nil, len(result))
+	return result, nil
+}
+
+// planOrderedBy returns true if the query plan finds the documents in the order of the values at the given path,
+// which is the case when the first field of the index that's used has the same path.
+func planOrderedBy(plan queryPlan, orderBy QueryPath) bool {
+	indexPlan, ok := plan.(resultScanQueryPlan)
+	if !ok {
+		return false
+	}
+	fields := indexPlan.index.Fields()
+	return len(fields) > 0 && fields[0].QueryPath().Equals(orderBy)
+}
+
+// sortReferences sorts the references by the smallest value at the orderBy path of their documents.
+// References of documents without a value at the path are moved to the end.
+func (c *collection) sortReferences(ctx context.Context, refs []Reference, docs map[string]Document, orderBy QueryPath, ascending bool) error {
+	transform := c.transformFor(orderByPath{orderBy})
+	sortKeys := make(map[string][]byte, len(refs))
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := c.ValuesAtPath(docs[string(ref)], orderBy)
+		if err != nil {
+			return err
+		}
+		var smallest []byte
+		for _, value := range values {
+			if transform != nil {
+				value = transform(value)
+			}
+			if smallest == nil || bytes.Compare(value.Bytes(), smallest) < 0 {
+				smallest = value.Bytes()
+			}
+		}
+		sortKeys[string(ref)] = smallest
+	}
+
+	sort.SliceStable(refs, func(a, b int) bool {
+		keyA, keyB := sortKeys[string(refs[a])], sortKeys[string(refs[b])]
+		if keyA == nil || keyB == nil {
+			return keyB == nil && keyA != nil
+		}
+		if ascending {
+			return bytes.Compare(keyA, keyB) < 0
+		}
+		return bytes.Compare(keyA, keyB) > 0
+	})
+	return nil
+}
+
+// orderByPath makes a QueryPath comparable to the fields of an index
+type orderByPath struct {
+	path QueryPath
+}
+
+func (o orderByPath) Equals(other QueryPathComparable) bool {
+	return o.path.Equals(other.QueryPath())
+}
+
+func (o orderByPath) QueryPath() QueryPath {
+	return o.path
+}
+
+func (c *collection) Count(ctx context.Context, query Query) (int, error) {
+	count := 0
+	query.limit = 0
+	query.skip = 0
+
+	if index := c.findIndex(query); index != nil {
+		outside, err := index.QueryPartsOutsideIndex(query)
+		if err != nil {
+			return 0, err
+		}
+		if len(outside) == 0 {
+			return c.countIndex(ctx, query, index)
+		}
+	}
+
+	err := c.iterate(query, func(_ Reference, _ []byte) error {
+		// stop iteration when needed
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// countIndex counts the documents matching the query by only scanning the index
+func (c *collection) countIndex(ctx context.Context, query Query, index Index) (int, error) {
+	count := 0
+	plan := indexScanQueryPlan{
+		queryPlanBase: queryPlanBase{
+			collection: c,
+			query:      query,
+		},
+		index: index,
+	}
+	// references are deduplicated by the plan, so every call is a unique document
+	err := plan.execute(ctx, func(_ []byte, _ []byte) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (c *collection) Distinct(ctx context.Context, path QueryPath) ([]Scalar, error) {
+	var values map[string]Scalar
+	var err error
+	if index := c.distinctIndex(path); index != nil {
+		values, err = c.distinctFromIndex(ctx, index)
+	} else {
+		values, err = c.distinctFromDocuments(ctx, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Scalar, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return bytes.Compare(result[a].Bytes(), result[b].Bytes()) < 0
+	})
+	return result, nil
+}
+
+// distinctIndex returns an index with a single field on the given path, or nil if there's none
+func (c *collection) distinctIndex(path QueryPath) Index {
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	for _, i := range c.indexList {
+		fields := i.Fields()
+		if len(fields) == 1 && fields[0].QueryPath().Equals(path) {
+			return i
+		}
+	}
+	return nil
+}
+
+// distinctFromIndex reads the keys of a single field index.
+// Keys only contain the bytes of a value, so the Scalar is taken from the first document that's indexed under the key.
+func (c *collection) distinctFromIndex(ctx context.Context, index Index) (map[string]Scalar, error) {
+	field := index.Fields()[0]
+	values := make(map[string]Scalar)
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		iBucket := bucket.Bucket(index.BucketName())
+		docBucket := bucket.Bucket(c.documentCollectionByteRef())
+		if iBucket == nil || docBucket == nil {
+			return nil
+		}
+
+		cursor := iBucket.Cursor()
+		for key, v := cursor.First(); key != nil; key, v = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// values are stored in sub-buckets, other keys contain the metadata of the index
+			if v != nil {
+				continue
+			}
+			value, err := c.indexedScalar(iBucket.Bucket(key), docBucket, index, field, key)
+			if err != nil {
+				return err
+			}
+			if value != nil {
+				// copy the key, it's only valid during the transaction
+				values[string(key)] = value
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// indexedScalar returns the Scalar of the first document in the refs bucket for which the field has a value with the given bytes.
+// It returns nil if no document is indexed under the key, which happens when all documents with the value have been deleted.
+func (c *collection) indexedScalar(refs *bbolt.Bucket, docBucket *bbolt.Bucket, index Index, field FieldIndexer, key []byte) (Scalar, error) {
+	cursor := refs.Cursor()
+	for ref, _ := cursor.First(); ref != nil; ref, _ = cursor.Next() {
+		data := docBucket.Get(ref)
+		if data == nil {
+			continue
+		}
+		c.stats.documentsFetched.Add(1)
+		doc, err := decodeDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := index.Keys(field, doc)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for _, k := range keys {
+			if bytes.Equal(k.Bytes(), key) {
+				return k, nil
+			}
 		}
 	}
-
-	return nil
+	return nil, nil
 }
 
-func (c *collection) Find(ctx context.Context, query Query) ([]Document, error) {
-	docs := make([]Document, 0)
-	walker := func(key Reference, value []byte) error {
-		// stop iteration when needed
-		if err := ctx.Err(); err != nil {
+// distinctFromDocuments collects the values at the path from all documents
+func (c *collection) distinctFromDocuments(ctx context.Context, path QueryPath) (map[string]Scalar, error) {
+	values := make(map[string]Scalar)
+	c.stats.fullTableScan.Add(1)
+	err := c.ForEach(ctx, func(_ Reference, doc []byte) error {
+		scalars, err := c.ValuesAtPath(doc, path)
+		if err != nil {
 			return err
 		}
-
-		docs = append(docs, value)
+		for _, scalar := range scalars {
+			values[string(scalar.Bytes())] = scalar
+		}
 		return nil
+	})
+	return values, err
+}
+
+func (c *collection) ExplainQuery(query Query) (string, error) {
+	plan, err := c.queryPlan(query)
+	if err != nil {
+		return "", err
 	}
+	return plan.Explain(), nil
+}
 
-	if err := c.Iterate(query, walker); err != nil {
-		return nil, err
+func (c *collection) Iterate(query Query, fn DocumentWalker) error {
+	c.stats.iterate.Add(1)
+	return c.iterate(query, fn)
+}
+
+func (c *collection) IterateFrom(query Query, bookmark []byte, walker DocumentWalker) ([]byte, error) {
+	c.stats.iterate.Add(1)
+	plan := fullTableScanQueryPlan{
+		queryPlanBase: queryPlanBase{
+			collection: c,
+			query:      query,
+		},
 	}
 
-	return docs, nil
+	bookmark, err := plan.executeFrom(bookmark, walker)
+	if errors.Is(err, ErrStopIteration) {
+		err = nil
+	}
+	return bookmark, err
 }
 
-func (c *collection) Iterate(query Query, fn DocumentWalker) error {
+func (c *collection) iterate(query Query, fn DocumentWalker) error {
 	plan, err := c.queryPlan(query)
 	if err != nil {
 		return err
@@ -249,8 +1643,37 @@ func (c *collection) Iterate(query Query, fn DocumentWalker) error {
 	return nil
 }
 
+func (c *collection) WalkDocuments(ctx context.Context, fn DocumentWalker) error {
+	return c.ForEach(ctx, fn)
+}
+
+func (c *collection) ForEach(ctx context.Context, walker DocumentWalker) error {
+	return c.db.View(func(tx *bbolt.Tx) error {
+		bucket := c.documentBucket(tx)
+		if bucket == nil {
+			// no bucket means no docs
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for ref, data := cursor.First(); ref != nil; ref, data = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			doc, err := decodeDocument(data)
+			if err != nil {
+				return err
+			}
+			if err := walker(ref, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // IndexIterate uses a query to loop over all keys and Entries in an index. It skips the resultScan and collect phase
-func (c *collection) IndexIterate(query Query, fn ReferenceScanFn) error {
+func (c *collection) IndexIterate(ctx context.Context, query Query, fn ReferenceScanFn) error {
 	index := c.findIndex(query)
 	if index == nil {
 		return ErrNoIndex
@@ -264,49 +1687,131 @@ func (c *collection) IndexIterate(query Query, fn ReferenceScanFn) error {
 		index: index,
 	}
 
-	return plan.execute(fn)
+	return plan.execute(ctx, fn)
 }
 
 // Delete a document from the store, this also removes the entries from indices
 func (c *collection) Delete(doc Document) error {
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
 	// find matching indices and remove hash from that index
-	return c.db.Update(func(tx *bbolt.Tx) error {
-		return c.delete(tx, doc)
+	start := time.Now()
+	var deleted bool
+	err := c.update(func(tx *bbolt.Tx) (err error) {
+		deleted, err = c.delete(tx, doc)
+		return err
 	})
+	if err == nil {
+		count := 0
+		if deleted {
+			count = 1
+			c.updateCount(-1)
+			pending.add(OpDelete, doc)
+		}
+		c.emit(EventDelete, start, c.refMake(doc), count)
+	}
+	return err
 }
 
-func (c *collection) delete(tx *bbolt.Tx, doc Document) error {
-	bucket := tx.Bucket([]byte(c.name))
-	if bucket == nil {
+func (c *collection) DeleteWhere(ctx context.Context, query Query) (int, error) {
+	plan, err := c.queryPlan(query)
+	if err != nil {
+		return 0, err
+	}
+	var docs []Document
+	scanner, err := plan.documentScanner(func(_ Reference, value []byte) error {
+		// stop iteration when needed
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// copy the document, it's only valid during the transaction
+		docs = append(docs, append(Document{}, value...))
 		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	ref := c.refMake(doc)
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	start := time.Now()
+	var deleted []Document
+	err = c.update(func(tx *bbolt.Tx) error {
+		// documents are collected first, since a bucket can't be modified while a cursor iterates over it
+		if err := plan.executeTx(tx, scanner); err != nil && !errors.Is(err, errLimitReached) {
+			return err
+		}
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			existed, err := c.delete(tx, doc)
+			if err != nil {
+				return err
+			}
+			if existed {
+				deleted = append(deleted, doc)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	c.updateCount(-len(deleted))
+	c.emit(EventDelete, start, nil, len(deleted))
+	pending.add(OpDelete, deleted...)
+	return len(deleted), nil
+}
+
+// delete removes the document and its index entries. It returns true if the document was stored.
+func (c *collection) delete(tx *bbolt.Tx, doc Document) (bool, error) {
+	return c.deleteRef(tx, c.refMake(doc), doc)
+}
+
+// deleteRef removes the document stored under ref and the index entries of doc.
+func (c *collection) deleteRef(tx *bbolt.Tx, ref Reference, doc Document) (bool, error) {
+	bucket := tx.Bucket([]byte(c.name))
+	if bucket == nil {
+		return false, nil
+	}
 
 	docBucket := c.documentBucket(tx)
 	if docBucket == nil {
-		return nil
+		return false, nil
 	}
+	existed := docBucket.Get(ref) != nil
 	err := docBucket.Delete(ref)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// indices
 	for _, i := range c.indexList {
 		err = i.Delete(bucket, ref, doc)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	return nil
+	return existed, nil
 }
 
 func (c *collection) queryPlan(query Query) (queryPlan, error) {
 	index := c.findIndex(query)
 
 	if index == nil {
+		// a query without parts matches all documents, so scanning them is expected
+		if len(query.parts) > 0 {
+			c.log().Warn("full table scan, no index matches the query", "query", describeQuery(query))
+		}
 		return fullTableScanQueryPlan{
 			queryPlanBase: queryPlanBase{
 				collection: c,
@@ -324,10 +1829,28 @@ func (c *collection) queryPlan(query Query) (queryPlan, error) {
 	}, nil
 }
 
+// transformFor returns the Transform of the first FieldIndexer with the same path as the query part, or nil if the path isn't indexed.
+func (c *collection) transformFor(part QueryPathComparable) Transform {
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	for _, i := range c.indexList {
+		for _, field := range i.Fields() {
+			if field.Equals(part) {
+				return field.Transform
+			}
+		}
+	}
+	return nil
+}
+
 // find a matching index.
 // The index may, at most, be one longer than the number of search options.
 // The longest index will win.
 func (c *collection) findIndex(query Query) Index {
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
 	// first map the indices to the number of matching search options
 	var cIndex Index
 	var cMatch float64
@@ -343,28 +1866,250 @@ func (c *collection) findIndex(query Query) Index {
 	return cIndex
 }
 
-func (c *collection) Get(key Reference) (Document, error) {
-	var err error
-	var data []byte
+func (c *collection) Exists(ref Reference) (bool, error) {
+	var exists bool
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := c.documentBucket(tx)
+		exists = bucket != nil && bucket.Get(ref) != nil
+		return nil
+	})
+	return exists, err
+}
+
+func (c *collection) Get(key Reference) (Document, bool, error) {
+	c.stats.get.Add(1)
+	var data Document
 
-	err = c.db.View(func(tx *bbolt.Tx) error {
+	err := c.db.View(func(tx *bbolt.Tx) error {
 		bucket := c.documentBucket(tx)
 		if bucket == nil {
 			return nil
 		}
 
-		data = bucket.Get(key)
+		stored := bucket.Get(key)
+		if stored == nil {
+			return nil
+		}
+		c.stats.documentsFetched.Add(1)
+		decoded, err := decodeDocument(stored)
+		if err != nil {
+			return err
+		}
+		// copy the data, it's only valid during the transaction
+		data = append(Document{}, decoded...)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, data != nil, nil
+}
+
+func (c *collection) GetOrAdd(doc Document) (Document, bool, error) {
+	var pending pendingEvents
+	defer c.watchers.notify(&pending, c.refMake)
+	c.indexLock.RLock()
+	defer c.indexLock.RUnlock()
+
+	var existing Document
+
+	start := time.Now()
+	err := c.update(func(tx *bbolt.Tx) error {
+		if bucket := c.documentBucket(tx); bucket != nil {
+			if stored := bucket.Get(c.refMake(doc)); stored != nil {
+				data, err := decodeDocument(stored)
+				if err != nil {
+					return err
+				}
+				// copy the data, it's only valid during the transaction
+				existing = make(Document, len(data))
+				copy(existing, data)
+				return nil
+			}
+		}
+
+		_, err := c.add(context.Background(), tx, []Document{doc})
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	c.updateCount(1)
+	c.emit(EventAdd, start, nil, 1)
+	pending.add(OpAdd, doc)
+	return doc, true, nil
+}
+
+func (c *collection) FindByReference(refs ...Reference) ([]Document, error) {
+	docs := make([]Document, 0, len(refs))
+	err := c.fetch(refs, func(_ Reference, doc Document) {
+		if doc != nil || c.missingPlaceholders {
+			docs = append(docs, doc)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (c *collection) GetMulti(refs []Reference) (map[string]Document, error) {
+	docs := make(map[string]Document, len(refs))
+	err := c.fetch(refs, func(ref Reference, doc Document) {
+		if doc != nil {
+			docs[string(ref)] = doc
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// fetch reads the documents for the given references within a single transaction and calls fn for every unique reference,
+// with a nil Document if it's missing. The Document is a copy, so it can be used after the transaction.
+func (c *collection) fetch(refs []Reference, fn func(ref Reference, doc Document)) error {
+	c.stats.get.Add(1)
+	return c.db.View(func(tx *bbolt.Tx) error {
+		bucket := c.documentBucket(tx)
+		seen := make(map[string]struct{}, len(refs))
+		for _, ref := range refs {
+			if _, ok := seen[string(ref)]; ok {
+				continue
+			}
+			seen[string(ref)] = struct{}{}
+
+			var stored []byte
+			if bucket != nil {
+				stored = bucket.Get(ref)
+			}
+			if stored == nil {
+				fn(ref, nil)
+				continue
+			}
+			c.stats.documentsFetched.Add(1)
+			data, err := decodeDocument(stored)
+			if err != nil {
+				return err
+			}
+			// copy the data, it's only valid during the transaction
+			fn(ref, append(Document{}, data...))
+		}
+		return nil
+	})
+}
+
+func (c *collection) SetDocumentLoader(loader ld.DocumentLoader) {
+	c.documentLoader = loader
+}
+
+func (c *collection) DocumentLoader() ld.DocumentLoader {
+	return c.documentLoader
+}
+
+func (c *collection) SetMetadata(key, value string) error {
+	if strings.HasPrefix(key, reservedMetadataPrefix) {
+		return fmt.Errorf("%w: %s", ErrReservedMetadataKey, key)
+	}
+	return c.update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
+		if err != nil {
+			return err
+		}
+		metaBucket, err := bucket.CreateBucketIfNotExists([]byte(collectionMetadataBucket))
+		if err != nil {
+			return err
+		}
+		return metaBucket.Put([]byte(key), []byte(value))
+	})
+}
+
+func (c *collection) GetMetadata(key string) (string, error) {
+	var value string
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		metaBucket := c.metadataBucket(tx)
+		if metaBucket == nil {
+			return ErrMetadataNotFound
+		}
+		data := metaBucket.Get([]byte(key))
+		if data == nil {
+			return ErrMetadataNotFound
+		}
+		value = string(data)
 		return nil
 	})
+	return value, err
+}
 
-	if data == nil {
-		return nil, nil
+func (c *collection) AllMetadata() (map[string]string, error) {
+	result := make(map[string]string)
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		metaBucket := c.metadataBucket(tx)
+		if metaBucket == nil {
+			return nil
+		}
+		return metaBucket.ForEach(func(k, v []byte) error {
+			if !strings.HasPrefix(string(k), reservedMetadataPrefix) {
+				result[string(k)] = string(v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	return data, err
+func (c *collection) metadataBucket(tx *bbolt.Tx) *bbolt.Bucket {
+	bucket := tx.Bucket([]byte(c.name))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Bucket([]byte(collectionMetadataBucket))
 }
 
 func (c *collection) DocumentCount() (int, error) {
+	if c.exactCount {
+		return c.countDocuments()
+	}
+
+	c.indexLock.RLock()
+	if c.docCountLoaded {
+		defer c.indexLock.RUnlock()
+		return int(c.docCount.Load()), nil
+	}
+	c.indexLock.RUnlock()
+
+	// writers hold the read lock, so no documents are added while counting
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+	if !c.docCountLoaded {
+		count, err := c.countDocuments()
+		if err != nil {
+			return 0, err
+		}
+		c.docCount.Store(int64(count))
+		c.docCountLoaded = true
+	}
+	return int(c.docCount.Load()), nil
+}
+
+// updateCount adds delta to the in-memory document count once it has been loaded.
+// The caller must hold the read lock on indexLock.
+func (c *collection) updateCount(delta int) {
+	if c.docCountLoaded {
+		c.docCount.Add(int64(delta))
+	}
+}
+
+// countDocuments counts the keys in the document bucket
+func (c *collection) countDocuments() (int, error) {
 	var count int
 	err := c.db.View(func(tx *bbolt.Tx) error {
 		bucket := c.documentBucket(tx)
@@ -378,12 +2123,87 @@ func (c *collection) DocumentCount() (int, error) {
 	return count, err
 }
 
+func (c *collection) AddHook(event EventType, fn func(EventData)) {
+	c.hooks.add(event, fn)
+}
+
+func (c *collection) RemoveHooks(event EventType) {
+	c.hooks.remove(event)
+}
+
+// emit calls the hooks for the event with the duration since start
+func (c *collection) emit(event EventType, start time.Time, ref Reference, docCount int) {
+	c.hooks.emit(event, EventData{
+		Collection: c.name,
+		Ref:        ref,
+		Duration:   time.Since(start),
+		DocCount:   docCount,
+	})
+}
+
+func (c *collection) Watch(ctx context.Context) <-chan DocumentEvent {
+	return c.watchers.subscribe(ctx)
+}
+
+func (c *collection) CollectionStats() CollectionStats {
+	return c.stats.snapshot()
+}
+
+func (c *collection) ResetStats() {
+	c.stats.reset()
+}
+
+// log returns the logger of the collection, records are dropped when no logger is configured
+func (c *collection) log() *slog.Logger {
+	if c.logger == nil {
+		return discardLogger
+	}
+	return c.logger
+}
+
+// update runs fn in a bbolt write transaction. Errors of bbolt itself, like a failing commit, are logged.
+// It returns ErrReadOnly for a read-only store.
+func (c *collection) update(fn func(tx *bbolt.Tx) error) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	var fnErr error
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		fnErr = fn(tx)
+		return fnErr
+	})
+	if err != nil && fnErr == nil {
+		c.log().Error("transaction failed", "error", err)
+	}
+	return err
+}
+
+// batch runs fn using bbolt's DB.Batch, like update.
+func (c *collection) batch(fn func(tx *bbolt.Tx) error) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	var fnErr error
+	err := c.db.Batch(func(tx *bbolt.Tx) error {
+		fnErr = fn(tx)
+		return fnErr
+	})
+	if err != nil && fnErr == nil {
+		c.log().Error("transaction failed", "error", err)
+	}
+	return err
+}
+
 func (c *collection) documentBucket(tx *bbolt.Tx) *bbolt.Bucket {
 	bucket := tx.Bucket([]byte(c.name))
 	if bucket == nil {
 		return nil
 	}
-	return bucket.Bucket(documentCollectionByteRef())
+	return bucket.Bucket(c.documentCollectionByteRef())
+}
+
+func (c *collection) documentCollectionByteRef() []byte {
+	return []byte(c.documentBucketName)
 }
 
 // valueCollector is responsible for going through the document and finding the Scalars that match the Query
@@ -394,7 +2214,7 @@ type valueCollector func(collection *collection, document Document, queryPath Qu
 func JSONPathValueCollector(_ *collection, document Document, queryPath QueryPath) ([]Scalar, error) {
 	jsonPath, ok := queryPath.(jsonPath)
 	if !ok {
-		return nil, ErrInvalidQuery
+		return nil, fmt.Errorf("%w: %s is not a JSON path", ErrInvalidQuery, queryPath)
 	}
 
 	if !gjson.ValidBytes(document) {
@@ -409,7 +2229,7 @@ func JSONPathValueCollector(_ *collection, document Document, queryPath QueryPat
 func JSONLDValueCollector(collection *collection, document Document, queryPath QueryPath) ([]Scalar, error) {
 	iriPath, ok := queryPath.(iriPath)
 	if !ok {
-		return nil, ErrInvalidQuery
+		return nil, fmt.Errorf("%w: %s is not an IRI path", ErrInvalidQuery, queryPath)
 	}
 
 	var input interface{}
@@ -468,6 +2288,10 @@ func valuesFromMapAtPath(expanded map[string]interface{}, termPath iriPath) []Sc
 		return valuesFromSliceAtPath(castList, termPath)
 	}
 
+	if termPath.Head() == iriWildcard {
+		return valuesFromMapAtWildcard(expanded, termPath)
+	}
+
 	if value, ok := expanded[termPath.Head()]; ok {
 		// the value should now be a slice
 		next, ok := value.([]interface{})
@@ -480,11 +2304,45 @@ func valuesFromMapAtPath(expanded map[string]interface{}, termPath iriPath) []Sc
 	return nil
 }
 
+// valuesFromMapAtWildcard continues the path for every IRI in the map. JSON-LD keywords (@id, @type, etc.) are skipped.
+func valuesFromMapAtWildcard(expanded map[string]interface{}, termPath iriPath) []Scalar {
+	// sort the keys for a stable result
+	keys := make([]string, 0, len(expanded))
+	for key := range expanded {
+		if !strings.HasPrefix(key, "@") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]Scalar, 0)
+	for _, key := range keys {
+		if next, ok := expanded[key].([]interface{}); ok {
+			result = append(result, valuesFromSliceAtPath(next, termPath.Tail())...)
+		}
+	}
+	return result
+}
+
 // ValuesAtPath returns a slice with the values found at the given JSON path query
 func (c *collection) ValuesAtPath(document Document, queryPath QueryPath) ([]Scalar, error) {
 	return c.valueCollector(c, document, queryPath)
 }
 
+// maxExactInteger is the largest integer from which all integers can be represented exactly by a float64
+const maxExactInteger = 1 << 53
+
+// numberScalar returns a Float64Scalar for a JSON number. Integers from 2^53 can't be represented exactly by a float64,
+// they're parsed from the raw JSON as Int64Scalar to keep their precision.
+func numberScalar(result gjson.Result) Scalar {
+	if math.Abs(result.Num) >= maxExactInteger {
+		if i, err := strconv.ParseInt(result.Raw, 10, 64); err == nil {
+			return Int64Scalar(i)
+		}
+	}
+	return Float64Scalar(result.Num)
+}
+
 func valuesFromResult(result gjson.Result) ([]Scalar, error) {
 	switch result.Type {
 	case gjson.String:
@@ -494,7 +2352,7 @@ func valuesFromResult(result gjson.Result) ([]Scalar, error) {
 	case gjson.False:
 		return []Scalar{BoolScalar(false)}, nil
 	case gjson.Number:
-		return []Scalar{Float64Scalar(result.Num)}, nil
+		return []Scalar{numberScalar(result)}, nil
 	case gjson.Null:
 		return []Scalar{}, nil
 	default:
@@ -20,8 +20,15 @@
 package leia
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -55,9 +62,20 @@ func TestCollection_AddIndex(t *testing.T) {
 		assert.Len(t, c.indexList, 1)
 	})
 
+	t.Run("error - duplicate name with other fields", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		err := c.AddIndex(c.NewIndex(i.Name(), NewFieldIndexer(NewJSONPath("path.part"), TransformerOption(ToLower))))
+
+		assert.ErrorIs(t, err, ErrIndexConflict)
+		assert.ErrorIs(t, err, ErrIndexSchemaMismatch)
+		assert.Len(t, c.indexList, 1)
+	})
+
 	t.Run("ok - new index adds refs", func(t *testing.T) {
 		db, c, i := testIndex(t)
-		err := c.Add([]Document{exampleDoc})
+		err := c.Add(context.TODO(), []Document{exampleDoc})
 		assert.NoError(t, err)
 		err = c.AddIndex(i)
 		assert.NoError(t, err)
@@ -66,10 +84,83 @@ func TestCollection_AddIndex(t *testing.T) {
 		assertSize(t, db, documentCollection, 1)
 	})
 
+	t.Run("error - new index with invalid document reports BackfillError", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		invalidDoc := Document("}")
+		_ = c.Add(context.TODO(), []Document{exampleDoc, invalidDoc})
+
+		err := c.AddIndex(i)
+
+		var backfillErr BackfillError
+		if !assert.ErrorAs(t, err, &backfillErr) {
+			return
+		}
+		assert.ErrorIs(t, err, ErrInvalidJSON)
+		assert.Equal(t, []Reference{c.Reference(invalidDoc)}, backfillErr.FailedDocuments)
+		assert.Len(t, c.indexList, 1)
+		assertIndexSize(t, db, i, 1)
+		assertSize(t, db, documentCollection, 2)
+	})
+
+	t.Run("error - new index with invalid document and strict backfill is rolled back", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		c.strictBackfill = true
+		_ = c.Add(context.TODO(), []Document{exampleDoc, Document("}")})
+
+		err := c.AddIndex(i)
+
+		assert.ErrorIs(t, err, ErrInvalidJSON)
+		assert.Len(t, c.indexList, 0)
+		assertIndexSize(t, db, i, 0)
+		assertSize(t, db, documentCollection, 2)
+	})
+
+	t.Run("error - stored index with different configuration", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		c2 := testCollectionWithDB(db)
+		i2 := c2.NewIndex(i.Name(), NewFieldIndexer(NewJSONPath("path.part"), TransformerOption(ToLower)))
+
+		err := c2.AddIndex(i2)
+
+		assert.ErrorIs(t, err, ErrIndexSchemaMismatch)
+		assert.Len(t, c2.indexList, 0)
+	})
+
+	t.Run("ok - stored index with different configuration is rebuilt", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		c2 := testCollectionWithDB(db)
+		c2.autoRebuild = true
+		i2 := c2.NewIndex(i.Name(), NewFieldIndexer(NewJSONPath("path.parts")))
+
+		err := c2.AddIndex(i2)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, c2.indexList, 1)
+		assertIndexed(t, db, i2, []byte("value1"), c.Reference(exampleDoc))
+		assertIndexSize(t, db, i2, 2)
+	})
+
+	t.Run("ok - stored index with same configuration", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		c2 := testCollectionWithDB(db)
+
+		err := c2.AddIndex(c2.NewIndex(i.Name(), NewFieldIndexer(NewJSONPath("path.part"))))
+
+		assert.NoError(t, err)
+	})
+
 	t.Run("ok - adding existing index does nothing", func(t *testing.T) {
 		db, c, i := testIndex(t)
 		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 
 		assertIndexSize(t, db, i, 1)
 
@@ -77,12 +168,123 @@ func TestCollection_AddIndex(t *testing.T) {
 
 		assertIndexSize(t, db, i, 1)
 	})
+
+	t.Run("error - reserved name", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex(documentCollection, NewFieldIndexer(NewJSONPath("path.part")))
+
+		err := c.AddIndex(i)
+
+		assert.ErrorIs(t, err, ErrInvalidIndexName)
+		assert.Empty(t, c.indexList)
+	})
+}
+
+func TestCollection_AddIndexWithOptions(t *testing.T) {
+	docs := make([]Document, 5)
+	for j := range docs {
+		docs[j] = []byte(fmt.Sprintf(`{"path": {"part": "%d"}}`, j))
+	}
+
+	t.Run("ok - progress is reported", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), docs)
+		var progress [][2]int
+
+		err := c.AddIndexWithOptions(i, WithProgressInterval(2), WithProgress(func(indexed, total int) {
+			progress = append(progress, [2]int{indexed, total})
+		}))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, [][2]int{{2, 5}, {4, 5}, {5, 5}}, progress)
+		assert.Len(t, c.indexList, 1)
+		assertIndexSize(t, db, i, 5)
+	})
+
+	t.Run("ok - default interval", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), docs)
+		var progress [][2]int
+
+		err := c.AddIndexWithOptions(i, WithProgressInterval(0), WithProgress(func(indexed, total int) {
+			progress = append(progress, [2]int{indexed, total})
+		}))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, [][2]int{{5, 5}}, progress)
+	})
+
+	t.Run("ok - not called without documents", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		called := false
+
+		err := c.AddIndexWithOptions(i, WithProgress(func(_, _ int) {
+			called = true
+		}))
+
+		assert.NoError(t, err)
+		assert.False(t, called)
+	})
+}
+
+func TestCollection_AddIndex_Concurrent(t *testing.T) {
+	_, c := testCollection(t)
+	_ = c.Add(context.TODO(), []Document{exampleDoc})
+	wg := sync.WaitGroup{}
+
+	for j := 0; j < 10; j++ {
+		wg.Add(2)
+		go func(j int) {
+			defer wg.Done()
+			_ = c.AddIndex(c.NewIndex(fmt.Sprintf("index%d", j), NewFieldIndexer(NewJSONPath("path.part"))))
+		}(j)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Find(context.Background(), New(Eq(NewJSONPath("path.part"), MustParseScalar("value"))))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, c.indexList, 10)
+}
+
+func TestCollection_Writes_Concurrent(t *testing.T) {
+	db, c := testCollection(t)
+	wg := sync.WaitGroup{}
+
+	for j := 0; j < 10; j++ {
+		wg.Add(3)
+		doc := []byte(fmt.Sprintf(`{"path": {"part": "%d"}}`, j))
+		go func(j int) {
+			defer wg.Done()
+			_ = c.AddIndex(c.NewIndex(fmt.Sprintf("index%d", j), NewFieldIndexer(NewJSONPath("path.part"))))
+		}(j)
+		go func() {
+			defer wg.Done()
+			_ = c.Add(context.TODO(), []Document{doc})
+		}()
+		go func(j int) {
+			defer wg.Done()
+			_ = c.DropIndex(fmt.Sprintf("index%d", j-1))
+			_ = c.Delete(exampleDoc)
+		}(j)
+	}
+	wg.Wait()
+
+	// every remaining index contains all documents, regardless of the order of the writes
+	for _, i := range c.ListIndices() {
+		assertIndexSize(t, db, i, 10)
+	}
 }
 
 func TestCollection_DropIndex(t *testing.T) {
 	t.Run("ok - dropping index removes refs", func(t *testing.T) {
 		db, c, i := testIndex(t)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 		_ = c.AddIndex(i)
 
 		if !assert.NoError(t, c.DropIndex(i.Name())) {
@@ -92,12 +294,29 @@ func TestCollection_DropIndex(t *testing.T) {
 		assertIndexSize(t, db, i, 0)
 	})
 
+	t.Run("ok - dropping index removes metadata", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		if !assert.NoError(t, c.DropIndex(i.Name())) {
+			return
+		}
+
+		_, err := i.Meta()
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+		err = c.db.View(func(tx *bbolt.Tx) error {
+			assert.Nil(t, getIndexMetadata(tx.Bucket([]byte(c.name)), i.BucketName()))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
 	t.Run("ok - dropping index leaves other indices at rest", func(t *testing.T) {
 		db, c, i := testIndex(t)
 		i2 := c.NewIndex("other",
 			NewFieldIndexer(NewJSONPath("path.part")),
 		)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 		_ = c.AddIndex(i)
 		_ = c.AddIndex(i2)
 
@@ -109,322 +328,2694 @@ func TestCollection_DropIndex(t *testing.T) {
 	})
 }
 
-func TestCollection_Add(t *testing.T) {
+func TestCollection_ListIndices(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		db, c := testCollection(t)
-		err := c.Add([]Document{exampleDoc})
-		if !assert.NoError(t, err) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		indices := c.ListIndices()
+
+		if !assert.Len(t, indices, 1) {
 			return
 		}
+		assert.Equal(t, i.Name(), indices[0].Name())
+		// modifying the result doesn't change the collection
+		indices[0] = nil
+		assert.NotNil(t, c.ListIndices()[0])
+	})
 
-		assertSize(t, db, documentCollection, 1)
+	t.Run("ok - no indices", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		assert.Empty(t, c.ListIndices())
 	})
 }
 
-func TestCollection_Delete(t *testing.T) {
-	t.Run("ok", func(t *testing.T) {
+func TestCollection_Repair(t *testing.T) {
+	t.Run("ok - orphan entries are removed", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		i2 := c.NewIndex("parts", NewFieldIndexer(NewJSONPath("path.parts")))
+		_ = c.AddIndex(i, i2)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		// remove the document without removing its index entries
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			return c.documentBucket(tx).Delete(c.Reference(exampleDoc))
+		})
+
+		removed, err := c.Repair()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 3, removed)
+		assertIndexSize(t, db, i, 1)
+		docs, _ := c.Find(context.TODO(), New(Eq(NewJSONPath("path.part"), MustParseScalar("value"))))
+		assert.Len(t, docs, 1)
+		// keys without references are removed as well
+		_ = db.View(func(tx *bbolt.Tx) error {
+			assert.Equal(t, 1, tx.Bucket([]byte(c.name)).Bucket(i2.BucketName()).Stats().BucketN-1)
+			return nil
+		})
+	})
+
+	t.Run("ok - consistent collection", func(t *testing.T) {
 		db, c, i := testIndex(t)
 		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		removed, err := c.Repair()
 
-		err := c.Delete(exampleDoc)
 		if !assert.NoError(t, err) {
 			return
 		}
+		assert.Equal(t, 0, removed)
+		assertIndexSize(t, db, i, 1)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		removed, err := c.Repair()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, removed)
+	})
+}
+
+func TestCollection_Truncate(t *testing.T) {
+	t.Run("ok - documents and index entries are removed", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.SetMetadata("key", "value")
+
+		if !assert.NoError(t, c.Truncate()) {
+			return
+		}
 
 		assertIndexSize(t, db, i, 0)
 		assertSize(t, db, documentCollection, 0)
+		assert.Len(t, c.indexList, 1)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 0, count)
+		_, err := c.GetMetadata("key")
+		assert.ErrorIs(t, err, ErrMetadataNotFound)
 	})
 
-	t.Run("ok - not added", func(t *testing.T) {
-		db, c, _ := testIndex(t)
+	t.Run("ok - documents added afterwards are indexed", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.Truncate()
+
+		err := c.Add(context.TODO(), []Document{exampleDoc})
 
-		err := c.Delete(exampleDoc)
 		if !assert.NoError(t, err) {
 			return
 		}
+		assertIndexSize(t, db, i, 1)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 1, count)
+	})
 
-		assertSize(t, db, documentCollection, 0)
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		assert.NoError(t, c.Truncate())
+		assert.NoError(t, c.Truncate())
 	})
 }
 
-func TestCollection_Find(t *testing.T) {
+func TestCollection_DropAllIndices(t *testing.T) {
+	t.Run("ok - all indices are removed", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		i2 := c.NewIndex("other",
+			NewFieldIndexer(NewJSONPath("path.part")),
+		)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i, i2)
+
+		if !assert.NoError(t, c.DropAllIndices()) {
+			return
+		}
+
+		assertIndexSize(t, db, i, 0)
+		assertIndexSize(t, db, i2, 0)
+		assert.Empty(t, c.indexList)
+		doc, _, _ := c.Get(c.Reference(exampleDoc))
+		assert.NotNil(t, doc)
+	})
+
+	t.Run("ok - removes stored indices that weren't added", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		c2 := testCollectionWithDB(db)
+
+		if !assert.NoError(t, c2.DropAllIndices()) {
+			return
+		}
+
+		assertIndexSize(t, db, i, 0)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		assert.NoError(t, c.DropAllIndices())
+		assert.NoError(t, c.DropAllIndices())
+	})
+}
+
+func TestCollection_ReindexAll(t *testing.T) {
+	t.Run("ok - stale entries are removed", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		// remove the document without updating the index
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			return testBucket(t, tx).Bucket([]byte(documentCollection)).Delete(c.Reference(exampleDoc))
+		})
+
+		if !assert.NoError(t, c.ReindexAll()) {
+			return
+		}
+
+		assertIndexSize(t, db, i, 0)
+	})
+
+	t.Run("ok - missing entries are added", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			return testBucket(t, tx).DeleteBucket(i.BucketName())
+		})
+
+		if !assert.NoError(t, c.ReindexAll()) {
+			return
+		}
+
+		assertIndexSize(t, db, i, 1)
+		// idempotent
+		assert.NoError(t, c.ReindexAll())
+		assertIndexSize(t, db, i, 1)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		assert.NoError(t, c.ReindexAll())
+		assertIndexSize(t, db, i, 0)
+	})
+
+	t.Run("error - strict backfill rolls back", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		c.strictBackfill = true
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			return testBucket(t, tx).Bucket([]byte(documentCollection)).Put([]byte("invalid"), []byte("{"))
+		})
+
+		err := c.ReindexAll()
+
+		assert.Error(t, err)
+		assertIndexSize(t, db, i, 1)
+	})
+}
+
+func TestCollection_RenameIndex(t *testing.T) {
 	key := NewJSONPath("path.part")
-	nonIndexed := NewJSONPath("non_indexed")
 
-	t.Run("ok", func(t *testing.T) {
+	t.Run("ok - queries find existing entries", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+
+		err := c.RenameIndex(i.Name(), "renamed")
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		if !assert.Len(t, c.indexList, 1) {
+			return
+		}
+		renamed := c.indexList[0]
+		assert.Equal(t, "renamed", renamed.Name())
+		assertIndexSize(t, db, renamed, 1)
+		assertIndexSize(t, db, i, 0)
+		count := 0
+		err = c.IndexIterate(context.Background(), New(Eq(key, MustParseScalar("value"))), func(key []byte, value []byte) error {
+			count++
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - metadata is moved", func(t *testing.T) {
 		_, c, i := testIndex(t)
 		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value")))
+		before, _ := i.Meta()
+
+		err := c.RenameIndex(i.Name(), "renamed")
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		meta, err := c.indexList[0].(*index).Meta()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, before, meta)
+		_, err = i.Meta()
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+	})
+
+	t.Run("ok - renamed index is not rebuilt on AddIndex", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_ = c.AddIndex(i)
+		_ = c.RenameIndex(i.Name(), "renamed")
+		c2 := testCollectionWithDB(db)
+
+		err := c2.AddIndex(c2.NewIndex("renamed", NewFieldIndexer(key)))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - not found", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		err := c.RenameIndex("unknown", "renamed")
+
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+	})
+
+	t.Run("error - new name taken", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i, c.NewIndex("other", NewFieldIndexer(key)))
+
+		err := c.RenameIndex(i.Name(), "other")
+
+		assert.ErrorIs(t, err, ErrIndexExists)
+		assert.Equal(t, i.Name(), c.indexList[0].Name())
+	})
+
+	t.Run("error - invalid new name", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		err := c.RenameIndex(i.Name(), "_documents")
+
+		assert.ErrorIs(t, err, ErrInvalidIndexName)
+		assert.Equal(t, i.Name(), c.indexList[0].Name())
+	})
+}
+
+func TestCollection_Add(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, c := testCollection(t)
+		err := c.Add(context.TODO(), []Document{exampleDoc})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assertSize(t, db, documentCollection, 1)
+	})
+
+	t.Run("ok - indexed value equal to an internal key", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		doc := Document(`{"path": {"part": "_meta"}}`)
+
+		err := c.Add(context.TODO(), []Document{doc})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assertIndexed(t, db, i, []byte("_meta"), c.Reference(doc))
+		found, err := c.Find(context.TODO(), New(Eq(NewJSONPath("path.part"), MustParseScalar("_meta"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, found, 1)
+		_, err = i.Meta()
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		_, c := testCollection(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := c.Add(ctx, []Document{exampleDoc})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("error - deadline exceeded during transaction rolls back", func(t *testing.T) {
+		_, c := testCollection(t)
+		ctx := &expiringContext{Context: context.Background(), calls: 2}
+
+		err := c.Add(ctx, []Document{exampleDoc, []byte(jsonExample2)})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		doc, _, _ := c.Get(c.Reference(exampleDoc))
+		assert.Nil(t, doc)
+	})
+}
+
+func TestCollection_Add_MaxBatchSize(t *testing.T) {
+	docs := []Document{
+		[]byte(`{"path": {"part": "a"}}`),
+		[]byte(`{"path": {"part": "b"}}`),
+		[]byte(`{"path": {"part": "c"}}`),
+		[]byte(`{"path": {"part": "d"}}`),
+		[]byte(`{"path": {"part": "e"}}`),
+	}
+
+	t.Run("ok - every batch uses its own transaction", func(t *testing.T) {
+		db, c := testCollection(t)
+		c.maxBatchSize = 2
+		transactions := 0
+		c.AddHook(EventAdd, func(_ EventData) {
+			transactions++
+		})
+
+		err := c.Add(context.TODO(), docs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, transactions)
+		assertSize(t, db, documentCollection, 5)
+	})
+
+	t.Run("error - documents of earlier batches remain added", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		c.maxBatchSize = 2
+		invalid := append(append([]Document{}, docs[:3]...), []byte(`{"path": {"part": {}}}`), docs[4])
+
+		err := c.Add(context.TODO(), invalid)
+
+		var batchErr BatchError
+		if !assert.ErrorAs(t, err, &batchErr) {
+			return
+		}
+		assert.Equal(t, 2, batchErr.Committed)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 2, count)
+	})
+}
+
+func TestCollection_AddBatch(t *testing.T) {
+	db, c := testCollection(t)
+
+	err := c.AddBatch([]Document{exampleDoc})
+
+	assert.NoError(t, err)
+	assertSize(t, db, documentCollection, 1)
+}
+
+func TestCollection_AddInBatches(t *testing.T) {
+	docs := []Document{
+		[]byte(`{"path": {"part": "a"}}`),
+		[]byte(`{"path": {"part": "b"}}`),
+		[]byte(`{"path": {"part": "c"}}`),
+		[]byte(`{"path": {"part": "d"}}`),
+		[]byte(`{"path": {"part": "e"}}`),
+	}
+
+	t.Run("ok - every batch uses its own transaction", func(t *testing.T) {
+		db, c := testCollection(t)
+		transactions := 0
+		c.AddHook(EventAdd, func(_ EventData) {
+			transactions++
+		})
+
+		count, err := c.AddInBatches(context.TODO(), docs, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count)
+		assert.Equal(t, 3, transactions)
+		assertSize(t, db, documentCollection, 5)
+	})
+
+	t.Run("error - documents of earlier batches remain added", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		invalid := append(append([]Document{}, docs[:3]...), []byte(`{"path": {"part": {}}}`), docs[4])
+
+		count, err := c.AddInBatches(context.TODO(), invalid, 2)
+
+		assert.Error(t, err)
+		assert.Equal(t, 2, count)
+		assertSize(t, db, documentCollection, 2)
+	})
+
+	t.Run("error - context cancelled stops after the current batch", func(t *testing.T) {
+		db, c := testCollection(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		c.AddHook(EventAdd, func(_ EventData) {
+			cancel()
+		})
+
+		count, err := c.AddInBatches(ctx, docs, 2)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2, count)
+		assertSize(t, db, documentCollection, 2)
+	})
+
+	t.Run("error - invalid batch size", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		_, err := c.AddInBatches(context.TODO(), docs, 0)
+
+		assert.EqualError(t, err, "batch size must be at least 1")
+	})
+}
+
+func TestCollection_AddFromArray(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		err := c.AddFromArray(context.TODO(), Document(`[{"path": {"part": "a"}}, {"path": {"part": "b"}}]`))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assertSize(t, db, documentCollection, 2)
+		assertIndexSize(t, db, i, 2)
+		doc, found, _ := c.Get(c.Reference(Document(`{"path": {"part": "b"}}`)))
+		assert.True(t, found)
+		assert.Equal(t, Document(`{"path": {"part": "b"}}`), doc)
+	})
+
+	t.Run("ok - empty array", func(t *testing.T) {
+		db, c := testCollection(t)
+
+		err := c.AddFromArray(context.TODO(), Document(`[]`))
+
+		assert.NoError(t, err)
+		assertSize(t, db, documentCollection, 0)
+	})
+
+	t.Run("error - not an array", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		err := c.AddFromArray(context.TODO(), exampleDoc)
+
+		assert.ErrorIs(t, err, ErrNotAnArray)
+	})
+
+	t.Run("error - invalid JSON", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		err := c.AddFromArray(context.TODO(), Document(`[{}`))
+
+		assert.ErrorIs(t, err, ErrInvalidJSON)
+	})
+}
+
+// expiringContext returns context.DeadlineExceeded after Err has been called the given number of times
+type expiringContext struct {
+	context.Context
+	calls int
+}
+
+func (e *expiringContext) Err() error {
+	e.calls--
+	if e.calls < 0 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestCollection_AddConcurrent(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		wg := sync.WaitGroup{}
+		errs := make(chan error, 10)
+
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				errs <- c.AddConcurrent([]Document{Document(fmt.Sprintf(`{"path": {"part": "value%d"}}`, j))})
+			}(j)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		assertSize(t, db, documentCollection, 10)
+		assertIndexSize(t, db, i, 10)
+	})
+}
+
+func TestCollection_AddTolerant(t *testing.T) {
+	t.Run("ok - invalid document is reported", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		invalid := Document("{")
+
+		result, err := c.AddTolerant(context.Background(), []Document{exampleDoc, invalid, []byte(jsonExample2)})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, result.Added)
+		if assert.Len(t, result.Errors, 1) {
+			assert.Equal(t, c.Reference(invalid), result.Errors[0].Ref)
+			assert.ErrorIs(t, result.Errors[0], ErrInvalidJSON)
+		}
+		assertSize(t, db, documentCollection, 2)
+		assertIndexSize(t, db, i, 2)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		_, c := testCollection(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := c.AddTolerant(ctx, []Document{exampleDoc})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, result.Added)
+	})
+
+	t.Run("error - database closed", func(t *testing.T) {
+		db, c := testCollection(t)
+		_ = db.Close()
+
+		result, err := c.AddTolerant(context.Background(), []Document{exampleDoc})
+
+		assert.ErrorIs(t, err, bbolt.ErrDatabaseNotOpen)
+		assert.Empty(t, result.Errors)
+	})
+}
+
+func TestCollection_Find_Paging(t *testing.T) {
+	key := NewJSONPath("path.part")
+	docs := make([]Document, 10)
+	for j := range docs {
+		docs[j] = Document(fmt.Sprintf(`{"path": {"part": "value%d"}}`, j))
+	}
+	query := New(Prefix(key, MustParseScalar("value")))
+
+	for _, indexed := range []bool{true, false} {
+		_, c, i := testIndex(t)
+		if indexed {
+			_ = c.AddIndex(i)
+		}
+		_ = c.Add(context.TODO(), docs)
+		all, _ := c.Find(context.Background(), query)
+
+		t.Run(fmt.Sprintf("ok - limit (indexed: %t)", indexed), func(t *testing.T) {
+			result, err := c.Find(context.Background(), query.WithLimit(3))
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, all[:3], result)
+		})
+
+		t.Run(fmt.Sprintf("ok - skip and limit (indexed: %t)", indexed), func(t *testing.T) {
+			result, err := c.Find(context.Background(), query.WithLimit(3).WithSkip(8))
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, all[8:], result)
+		})
+
+		t.Run(fmt.Sprintf("ok - skip beyond results (indexed: %t)", indexed), func(t *testing.T) {
+			result, err := c.Find(context.Background(), query.WithSkip(10))
+
+			assert.NoError(t, err)
+			assert.Empty(t, result)
+		})
+
+		t.Run(fmt.Sprintf("ok - Count ignores paging (indexed: %t)", indexed), func(t *testing.T) {
+			count, err := c.Count(context.Background(), query.WithLimit(3).WithSkip(1))
+
+			assert.NoError(t, err)
+			assert.Equal(t, 10, count)
+		})
+	}
+}
+
+func TestCollection_Distinct(t *testing.T) {
+	issuer := NewJSONPath("issuer")
+	docs := []Document{
+		[]byte(`{"issuer": "B", "id": 1}`),
+		[]byte(`{"issuer": "a", "id": 2}`),
+		[]byte(`{"issuer": "B", "id": 3}`),
+		[]byte(`{"issuer": ["c", "a"], "id": 4}`),
+		[]byte(`{"id": 5}`),
+	}
+
+	t.Run("ok - full table scan", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), docs)
+
+		values, err := c.Distinct(context.TODO(), issuer)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("B"), StringScalar("a"), StringScalar("c")}, values)
+		assert.Equal(t, int64(1), c.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - from index", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.AddIndex(c.NewIndex("issuer", NewFieldIndexer(issuer, TransformerOption(ToLower))))
+		_ = c.Add(context.TODO(), docs)
+		_ = c.Delete(docs[3])
+
+		values, err := c.Distinct(context.TODO(), issuer)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("a"), StringScalar("b")}, values)
+		assert.Equal(t, int64(0), c.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - numbers from index", func(t *testing.T) {
+		_, c := testCollection(t)
+		id := NewJSONPath("id")
+		_ = c.AddIndex(c.NewIndex("id", NewFieldIndexer(id)))
+		_ = c.Add(context.TODO(), docs[:2])
+
+		values, err := c.Distinct(context.TODO(), id)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{Float64Scalar(1), Float64Scalar(2)}, values)
+	})
+
+	t.Run("ok - compound index isn't used", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.AddIndex(c.NewIndex("compound", NewFieldIndexer(issuer), NewFieldIndexer(NewJSONPath("id"))))
+		_ = c.Add(context.TODO(), docs)
+
+		values, err := c.Distinct(context.TODO(), issuer)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, values, 3)
+		assert.Equal(t, int64(1), c.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.AddIndex(c.NewIndex("issuer", NewFieldIndexer(issuer)))
+
+		values, err := c.Distinct(context.TODO(), issuer)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Empty(t, values)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.AddIndex(c.NewIndex("issuer", NewFieldIndexer(issuer)))
+		_ = c.Add(context.TODO(), docs)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.Distinct(ctx, issuer)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCollection_Count(t *testing.T) {
+	key := NewJSONPath("path.part")
+	docs := []Document{exampleDoc, []byte(jsonExample2), []byte(`{"path": {"part": "other"}}`)}
+
+	t.Run("ok - index scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+
+		count, err := c.Count(context.Background(), New(Eq(key, MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, count)
+		assert.Equal(t, int64(0), c.CollectionStats().DocumentsFetched)
+	})
+
+	t.Run("ok - result scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+
+		count, err := c.Count(context.Background(), New(Eq(key, MustParseScalar("value"))).And(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - full table scan", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), docs)
+
+		count, err := c.Count(context.Background(), New(Prefix(key, MustParseScalar("o"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		for _, indexed := range []bool{true, false} {
+			_, c, i := testIndex(t)
+			if indexed {
+				_ = c.AddIndex(i)
+			}
+			_ = c.Add(context.TODO(), docs)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := c.Count(ctx, New(Eq(key, MustParseScalar("value"))))
+
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	})
+}
+
+func TestCollection_Delete(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		err := c.Delete(exampleDoc)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assertIndexSize(t, db, i, 0)
+		assertSize(t, db, documentCollection, 0)
+	})
+
+	t.Run("ok - not added", func(t *testing.T) {
+		db, c, _ := testIndex(t)
+
+		err := c.Delete(exampleDoc)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assertSize(t, db, documentCollection, 0)
+	})
+}
+
+func TestCollection_ExplainQuery(t *testing.T) {
+	key := NewJSONPath("path.part")
+
+	t.Run("ok - index scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		q := New(Eq(key, MustParseScalar("value"))).And(Suffix(NewJSONPath("other"), MustParseScalar("x"))).WithLimit(10)
+
+		explanation, err := c.ExplainQuery(q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, fmt.Sprintf(`index scan using index %q: score 1
+inside index:
+  - path.part == value
+seek:
+  - path.part: "value"
+outside index:
+  - other ends with x
+limit: 10
+`, i.Name()), explanation)
+		assert.Equal(t, int64(0), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - full table scan without indices", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		explanation, err := c.ExplainQuery(New(Eq(key, MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, `full table scan
+reason: the collection has no indices
+filtered:
+  - path.part == value
+`, explanation)
+	})
+
+	t.Run("ok - full table scan without matching index", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		explanation, err := c.ExplainQuery(New(Suffix(key, MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, fmt.Sprintf(`full table scan
+reason: no index matches the query
+index %q: score 0
+not indexable: path.part ends with value
+filtered:
+  - path.part ends with value
+`, i.Name()), explanation)
+	})
+}
+
+func TestCollection_DeleteWhere(t *testing.T) {
+	kind := NewJSONPath("kind")
+	name := NewJSONPath("name")
+	docs := []Document{
+		[]byte(`{"kind": "x", "name": "a"}`),
+		[]byte(`{"kind": "x", "name": "b"}`),
+		[]byte(`{"kind": "y", "name": "c"}`),
+	}
+	setup := func(t *testing.T) (*bbolt.DB, *collection, Index, Index) {
+		db, c := testCollection(t)
+		kindIndex := c.NewIndex("kind", NewFieldIndexer(kind))
+		nameIndex := c.NewIndex("name", NewFieldIndexer(name))
+		_ = c.AddIndex(kindIndex, nameIndex)
+		_ = c.Add(context.TODO(), docs)
+		return db, c, kindIndex, nameIndex
+	}
+
+	t.Run("ok - entries are removed from all indices", func(t *testing.T) {
+		db, c, kindIndex, nameIndex := setup(t)
+
+		count, err := c.DeleteWhere(context.TODO(), New(Eq(kind, MustParseScalar("x"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, count)
+		assertSize(t, db, documentCollection, 1)
+		assertIndexSize(t, db, kindIndex, 1)
+		assertIndexSize(t, db, nameIndex, 1)
+		documentCount, _ := c.DocumentCount()
+		assert.Equal(t, 1, documentCount)
+	})
+
+	t.Run("ok - full table scan", func(t *testing.T) {
+		db, c, kindIndex, nameIndex := setup(t)
+
+		count, err := c.DeleteWhere(context.TODO(), New(Suffix(name, MustParseScalar("b"))))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, int64(1), c.CollectionStats().FullTableScanCount)
+		assertIndexSize(t, db, kindIndex, 2)
+		assertIndexSize(t, db, nameIndex, 2)
+	})
+
+	t.Run("ok - with limit", func(t *testing.T) {
+		db, c, _, _ := setup(t)
+
+		count, err := c.DeleteWhere(context.TODO(), New(Eq(kind, MustParseScalar("x"))).WithLimit(1))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assertSize(t, db, documentCollection, 2)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		count, err := c.DeleteWhere(context.TODO(), New(Eq(kind, MustParseScalar("x"))))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		db, c, _, _ := setup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.DeleteWhere(ctx, New(Eq(kind, MustParseScalar("x"))))
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assertSize(t, db, documentCollection, 3)
+	})
+}
+
+func TestCollection_Find(t *testing.T) {
+	key := NewJSONPath("path.part")
+	nonIndexed := NewJSONPath("non_indexed")
+
+	t.Run("ok", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - with ResultScan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value"))).And(Eq(nonIndexed, MustParseScalar("value")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - query spanning two indices", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		parts := NewJSONPath("path.parts")
+		_ = c.AddIndex(i, c.NewIndex("parts", NewFieldIndexer(parts)))
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		// one part is resolved by an index, the other is evaluated by the resultScanner using its own path
+		q := New(Eq(key, MustParseScalar("value"))).And(Eq(parts, MustParseScalar("value2")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		if assert.Len(t, docs, 1) {
+			assert.Equal(t, Document(jsonExample2), docs[0])
+		}
+	})
+
+	t.Run("ok - range on binary values", func(t *testing.T) {
+		_, c := testCollection(t)
+		keyID := NewJSONPath("kid")
+		hexDecode := func(s Scalar) Scalar {
+			if str, ok := s.(StringScalar); ok {
+				b, _ := hex.DecodeString(string(str))
+				return BytesScalar(b)
+			}
+			return s
+		}
+		_ = c.AddIndex(c.NewIndex("kid", NewFieldIndexer(keyID, TransformerOption(hexDecode))))
+		docs := []Document{
+			[]byte(`{"kid": "00ff"}`),
+			[]byte(`{"kid": "0110"}`),
+			[]byte(`{"kid": "0200"}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(Range(keyID, BytesScalar{0x00, 0x00}, BytesScalar{0x01, 0xff}))
+
+		result, err := c.SortedFind(context.TODO(), q, keyID, true)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[0], docs[1]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - with Or", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		docs := []Document{
+			[]byte(`{"path": {"part": "a"}}`),
+			[]byte(`{"path": {"part": "b"}}`),
+			[]byte(`{"path": {"part": "c"}}`),
+			[]byte(`{"path": {"part": "d"}}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(Or(Eq(key, MustParseScalar("d")), Eq(key, MustParseScalar("b")), Prefix(key, MustParseScalar("b"))))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[1], docs[3]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - with Or on compound index", func(t *testing.T) {
+		_, c := testCollection(t)
+		kind := NewJSONPath("kind")
+		_ = c.AddIndex(c.NewIndex("compound", NewFieldIndexer(kind), NewFieldIndexer(key)))
+		docs := []Document{
+			[]byte(`{"kind": "x", "path": {"part": "a"}}`),
+			[]byte(`{"kind": "x", "path": {"part": "b"}}`),
+			[]byte(`{"kind": "y", "path": {"part": "a"}}`),
+			[]byte(`{"kind": "z", "path": {"part": "c"}}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(Or(Eq(kind, MustParseScalar("x")), Eq(kind, MustParseScalar("z")))).And(Or(Eq(key, MustParseScalar("a")), Eq(key, MustParseScalar("c"))))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[0], docs[3]}, result)
+	})
+
+	t.Run("ok - exclusive bounds", func(t *testing.T) {
+		docs := []Document{
+			[]byte(`{"path": {"part": "a"}}`),
+			[]byte(`{"path": {"part": "b"}}`),
+			[]byte(`{"path": {"part": "c"}}`),
+			[]byte(`{"path": {"part": "d"}}`),
+			[]byte(`{"other": "e"}`),
+		}
+		queries := map[string]Query{
+			"greater than":               New(GreaterThan(key, MustParseScalar("b"))),
+			"less than":                  New(LessThan(key, MustParseScalar("c"))),
+			"greater than and less than": New(GreaterThan(key, MustParseScalar("a"))).And(LessThan(key, MustParseScalar("d"))),
+			"greater than and range":     New(GreaterThan(key, MustParseScalar("a"))).And(Range(key, MustParseScalar("a"), MustParseScalar("b"))),
+			"or greater than":            New(Or(GreaterThan(key, MustParseScalar("b")))),
+			"or equal and greater than":  New(Or(Eq(key, MustParseScalar("a")), GreaterThan(key, MustParseScalar("c")))),
+			"or less than and equal":     New(Or(LessThan(key, MustParseScalar("b")), Eq(key, MustParseScalar("d")))),
+		}
+		expected := map[string][]Document{
+			"greater than":               {docs[2], docs[3]},
+			"less than":                  {docs[0], docs[1]},
+			"greater than and less than": {docs[1], docs[2]},
+			"greater than and range":     {docs[1]},
+			"or greater than":            {docs[2], docs[3]},
+			"or equal and greater than":  {docs[0], docs[3]},
+			"or less than and equal":     {docs[0], docs[3]},
+		}
+		for name, q := range queries {
+			t.Run(name, func(t *testing.T) {
+				_, c, i := testIndex(t)
+				_ = c.AddIndex(i)
+				_ = c.Add(context.TODO(), docs)
+				_, c2 := testCollection(t)
+				_ = c2.Add(context.TODO(), docs)
+
+				indexed, err := c.Find(context.TODO(), q)
+				if !assert.NoError(t, err) {
+					return
+				}
+				scanned, err := c2.Find(context.TODO(), q)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.ElementsMatch(t, expected[name], indexed)
+				assert.ElementsMatch(t, expected[name], scanned)
+				assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+
+				count, err := c.Count(context.TODO(), q)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, len(expected[name]), count)
+			})
+		}
+	})
+
+	t.Run("ok - exclusive bound on compound index", func(t *testing.T) {
+		_, c := testCollection(t)
+		kind := NewJSONPath("kind")
+		_ = c.AddIndex(c.NewIndex("compound", NewFieldIndexer(kind), NewFieldIndexer(key)))
+		docs := []Document{
+			[]byte(`{"kind": "x", "path": {"part": "a"}}`),
+			[]byte(`{"kind": "x", "path": {"part": "b"}}`),
+			[]byte(`{"kind": "y", "path": {"part": "a"}}`),
+			[]byte(`{"kind": "z", "path": {"part": "b"}}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.Find(context.TODO(), New(GreaterThan(kind, MustParseScalar("x"))).And(GreaterThan(key, MustParseScalar("a"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[3]}, result)
+	})
+
+	t.Run("ok - key delimiter within the last field", func(t *testing.T) {
+		_, c := testCollection(t)
+		toBytes := func(scalar Scalar) Scalar {
+			return BytesScalar(scalar.Bytes())
+		}
+		_ = c.AddIndex(c.NewIndex("compound", NewFieldIndexer(NewJSONPath("kind")), NewFieldIndexer(key, TransformerOption(toBytes))))
+		doc := Document(`{"kind": "x", "path": {"part": "a\u0010b"}}`)
+		_ = c.Add(context.TODO(), []Document{doc})
+
+		result, err := c.Find(context.TODO(), New(Eq(NewJSONPath("kind"), MustParseScalar("x"))).And(Eq(key, MustParseScalar("a\x10b"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{doc}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - NotNil on a field outside the index", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		other := NewJSONPath("other")
+		docs := []Document{
+			[]byte(`{"path": {"part": "a"}, "other": "value"}`),
+			[]byte(`{"path": {"part": "a"}, "other": ["value"]}`),
+			[]byte(`{"path": {"part": "a"}}`),
+			[]byte(`{"path": {"part": "a"}, "other": null}`),
+			[]byte(`{"path": {"part": "a"}, "other": []}`),
+			[]byte(`{"path": {"part": "b"}, "other": "value"}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.Find(context.TODO(), New(Eq(key, MustParseScalar("a"))).And(NotNil(other)))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[0], docs[1]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - compound index with a part outside the index", func(t *testing.T) {
+		_, c := testCollection(t)
+		kind := NewJSONPath("kind")
+		name := NewJSONPath("name")
+		_ = c.AddIndex(c.NewIndex("compound", NewFieldIndexer(kind), NewFieldIndexer(key)))
+		docs := []Document{
+			[]byte(`{"kind": "x", "path": {"part": "a"}, "name": "Jane"}`),
+			[]byte(`{"kind": "x", "path": {"part": "a"}, "name": "John"}`),
+			[]byte(`{"kind": "y", "path": {"part": "a"}, "name": "Jane"}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		// both fields of the index are matched by the path of the query part, the remaining part is evaluated on the documents using its own path
+		q := New(Eq(key, MustParseScalar("a"))).And(Eq(name, MustParseScalar("Jane"))).And(Eq(kind, MustParseScalar("x")))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[0]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - with Or on non-indexed field", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(`{"non_indexed": "other"}`), []byte(`{"non_indexed": "none"}`)})
+		q := New(Or(Eq(nonIndexed, MustParseScalar("value")), Eq(nonIndexed, MustParseScalar("other"))))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, result, 2)
+		assert.Equal(t, int64(1), c.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - prefix outside the used index applies the transform of its field", func(t *testing.T) {
+		_, c := testCollection(t)
+		kind := NewJSONPath("kind")
+		name := NewJSONPath("name")
+		_ = c.AddIndex(c.NewIndex("kind", NewFieldIndexer(kind)), c.NewIndex("name", NewFieldIndexer(name, TransformerOption(ToLower))))
+		docs := []Document{
+			[]byte(`{"kind": "x", "name": "John"}`),
+			[]byte(`{"kind": "x", "name": "Mary"}`),
+			[]byte(`{"kind": "y", "name": "Joan"}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(Eq(kind, MustParseScalar("x"))).And(Prefix(name, MustParseScalar("JO")))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[0]}, result)
+	})
+
+	t.Run("ok - with In", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		docs := []Document{
+			[]byte(`{"path": {"part": "a"}}`),
+			[]byte(`{"path": {"part": "b"}}`),
+			[]byte(`{"path": {"part": "c"}}`),
+			[]byte(`{"path": {"part": "d"}}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(In(key, MustParseScalar("d"), MustParseScalar("b"), MustParseScalar("x")))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[1], docs[3]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - with In on non-indexed field", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(`{"non_indexed": "other"}`), []byte(`{"non_indexed": "none"}`)})
+		q := New(In(nonIndexed, MustParseScalar("value"), MustParseScalar("other")))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, result, 2)
+	})
+
+	t.Run("ok - with range query on Int64Scalar index", func(t *testing.T) {
+		_, c := testCollection(t)
+		sequence := NewJSONPath("sequence")
+		_ = c.AddIndex(c.NewIndex("sequence", NewFieldIndexer(sequence, TransformerOption(ToInt64))))
+		docs := []Document{
+			[]byte(`{"sequence": -2}`),
+			[]byte(`{"sequence": "9007199254740993"}`),
+			[]byte(`{"sequence": "9007199254740994"}`),
+			[]byte(`{"sequence": 5}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		q := New(Range(sequence, IntScalar(-5), Int64Scalar(9007199254740993)))
+
+		result, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[0], docs[1], docs[3]}, result)
+	})
+
+	t.Run("ok - JSON integers beyond float64 precision", func(t *testing.T) {
+		_, c := testCollection(t)
+		sequence := NewJSONPath("sequence")
+		_ = c.AddIndex(c.NewIndex("sequence", NewFieldIndexer(sequence, TransformerOption(ToInt64))))
+		docs := []Document{
+			[]byte(`{"sequence": 9007199254740992}`),
+			[]byte(`{"sequence": 9007199254740993}`),
+			[]byte(`{"sequence": 5}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.Find(context.TODO(), New(Eq(sequence, Int64Scalar(9007199254740993))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[1]}, result)
+		result, err = c.Find(context.TODO(), New(GreaterThan(sequence, IntScalar(5))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.ElementsMatch(t, []Document{docs[0], docs[1]}, result)
+	})
+
+	t.Run("ok - regex on indexed field uses full table scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(`{"path": {"part": "other"}}`)})
+		q := New(Regex(key, regexp.MustCompile(`^val`)))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, docs, 1)
+		assert.Equal(t, int64(1), c.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - with ResultScan and regex", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		q := New(Eq(key, MustParseScalar("value"))).And(Regex(nonIndexed, regexp.MustCompile(`^v.*e$`)))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("error - regex on number", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Regex(NewJSONPath("path.more.#.parts"), regexp.MustCompile(`0`)))
+
+		_, err := c.Find(context.TODO(), q)
+
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+
+	t.Run("ok - with Full table scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(nonIndexed, MustParseScalar("value")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - with ResultScan and range query", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value"))).And(Range(nonIndexed, MustParseScalar("v"), MustParseScalar("value1")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - with ResultScan, range query not found", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value"))).And(
+			Range(nonIndexed, MustParseScalar("value1"), MustParseScalar("value2")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 0)
+	})
+
+	t.Run("ok - with ResultScan and suffix query", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value"))).And(Suffix(nonIndexed, MustParseScalar("lue")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - suffix query on indexed field uses full table scan", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Suffix(key, MustParseScalar("alue")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Nil(t, c.findIndex(q))
+		assert.Len(t, docs, 1)
+	})
+
+	t.Run("ok - no docs", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		q := New(Eq(key, MustParseScalar("value")))
+
+		docs, err := c.Find(context.TODO(), q)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, docs, 0)
+	})
+
+	t.Run("error - ctx cancelled", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value")))
+		ctx, cancelFn := context.WithCancel(context.Background())
+
+		cancelFn()
+		_, err := c.Find(ctx, q)
+
+		if !assert.Error(t, err) {
+			return
+		}
+
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("error - deadline exceeded", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		q := New(Eq(key, MustParseScalar("value")))
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancelFn()
+
+		_, err := c.Find(ctx, q)
+
+		if !assert.Error(t, err) {
+			return
+		}
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+func TestCollection_SortedFind(t *testing.T) {
+	key := NewJSONPath("path.part")
+	rank := NewJSONPath("rank")
+	docs := []Document{
+		[]byte(`{"path": {"part": "c"}, "rank": 1}`),
+		[]byte(`{"path": {"part": "a"}}`),
+		[]byte(`{"path": {"part": "d"}, "rank": 3}`),
+		[]byte(`{"path": {"part": "b"}, "rank": 2}`),
+	}
+	all := New(Range(key, MustParseScalar("a"), MustParseScalar("z")))
+
+	t.Run("ok - ascending in index order", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.SortedFind(context.TODO(), all, key, true)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[1], docs[3], docs[0], docs[2]}, result)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - descending on indexed field", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.SortedFind(context.TODO(), all, key, false)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[2], docs[0], docs[3], docs[1]}, result)
+	})
+
+	t.Run("ok - sorted on field outside index, missing values last", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+
+		ascending, err := c.SortedFind(context.TODO(), all, rank, true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		descending, err := c.SortedFind(context.TODO(), all, rank, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Document{docs[0], docs[3], docs[2], docs[1]}, ascending)
+		assert.Equal(t, []Document{docs[2], docs[3], docs[0], docs[1]}, descending)
+	})
+
+	t.Run("ok - full table scan", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), docs)
+
+		result, err := c.SortedFind(context.TODO(), New(NotNil(rank)), key, true)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{docs[3], docs[0], docs[2]}, result)
+	})
+
+	t.Run("ok - limit and skip are applied after sorting", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+		q := all.WithLimit(2).WithSkip(1)
+
+		inIndexOrder, err := c.SortedFind(context.TODO(), q, key, true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		sorted, err := c.SortedFind(context.TODO(), q, key, false)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Document{docs[3], docs[0]}, inIndexOrder)
+		assert.Equal(t, []Document{docs[0], docs[3]}, sorted)
+	})
+
+	t.Run("ok - uses the transform of the indexed field", func(t *testing.T) {
+		_, c := testCollection(t)
+		name := NewJSONPath("name")
+		_ = c.AddIndex(c.NewIndex("name", NewFieldIndexer(name, TransformerOption(ToLower))))
+		upper := []Document{[]byte(`{"name": "B", "rank": 1}`), []byte(`{"name": "a", "rank": 2}`)}
+		_ = c.Add(context.TODO(), upper)
+
+		result, err := c.SortedFind(context.TODO(), New(NotNil(rank)), name, true)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{upper[1], upper[0]}, result)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.SortedFind(ctx, all, rank, true)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCollection_IterateFrom(t *testing.T) {
+	key := NewJSONPath("path.part")
+	docs := make([]Document, 6)
+	for j := range docs {
+		docs[j] = Document(fmt.Sprintf(`{"path": {"part": "value%d"}, "even": %t}`, j, j%2 == 0))
+	}
+	query := New(Prefix(key, MustParseScalar("value")))
+
+	t.Run("ok - resume after stop", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), docs)
+		var refs []string
+		walker := func(key Reference, value []byte) error {
+			refs = append(refs, key.EncodeToString())
+			if len(refs) == 2 {
+				return ErrStopIteration
+			}
+			return nil
+		}
+
+		bookmark, err := c.IterateFrom(query, nil, walker)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, refs, 2)
+		assert.Equal(t, refs[1], Reference(bookmark).EncodeToString())
+		bookmark, err = c.IterateFrom(query, bookmark, walker)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, refs, 6)
+		assert.IsIncreasing(t, refs)
+		_, err = c.IterateFrom(query, bookmark, walker)
+		assert.NoError(t, err)
+		assert.Len(t, refs, 6)
+	})
+
+	t.Run("ok - bookmark includes documents that don't match", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), docs)
+		count := 0
+
+		bookmark, err := c.IterateFrom(New(Eq(NewJSONPath("even"), MustParseScalar(true))), nil, func(key Reference, value []byte) error {
+			count++
+			return nil
+		})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 3, count)
+		var last Reference
+		_ = c.ForEach(context.Background(), func(ref Reference, _ []byte) error {
+			last = ref
+			return nil
+		})
+		assert.Equal(t, last, Reference(bookmark))
+	})
+
+	t.Run("error - walker error returns last processed document", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), docs)
+		var first Reference
+
+		bookmark, err := c.IterateFrom(query, nil, func(key Reference, value []byte) error {
+			if first == nil {
+				first = append(Reference{}, key...)
+				return nil
+			}
+			return errors.New("b00m")
+		})
+
+		assert.EqualError(t, err, "b00m")
+		assert.Equal(t, first, Reference(bookmark))
+	})
+}
+
+func TestCollection_Iterate(t *testing.T) {
+	key := NewJSONPath("path.part")
+
+	_, c, i := testIndex(t)
+	_ = c.AddIndex(i)
+	_ = c.Add(context.TODO(), []Document{exampleDoc})
+	q := New(Eq(key, MustParseScalar("value")))
+
+	t.Run("ok - count fn", func(t *testing.T) {
+		count := 0
+
+		err := c.Iterate(q, func(key Reference, value []byte) error {
+			count++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - document indexed multiple times, query should un double", func(t *testing.T) {
+		doc := []byte(jsonExample)
+		doc2 := []byte(jsonExample2)
+		count := 0
+
+		i := c.NewIndex(t.Name(),
+			NewFieldIndexer(NewJSONPath("path.part")),
+			NewFieldIndexer(NewJSONPath("path.more.#.parts")),
+		)
+
+		_, c := testCollection(t)
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{doc, doc2})
+
+		err := c.Iterate(q, func(key Reference, value []byte) error {
+			count++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := c.Iterate(q, func(key Reference, value []byte) error {
+			return errors.New("b00m")
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestCollection_WalkDocuments(t *testing.T) {
+	_, c := testCollection(t)
+	_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+
+	t.Run("ok - count fn", func(t *testing.T) {
+		count := 0
+
+		err := c.WalkDocuments(context.Background(), func(key Reference, value []byte) error {
+			count++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		err := c.WalkDocuments(context.Background(), func(key Reference, value []byte) error {
+			return errors.New("b00m")
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - ctx cancelled", func(t *testing.T) {
+		ctx, cancelFn := context.WithCancel(context.Background())
+		cancelFn()
+
+		err := c.WalkDocuments(ctx, func(key Reference, value []byte) error {
+			return nil
+		})
+
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := c.WalkDocuments(context.Background(), func(key Reference, value []byte) error {
+			return errors.New("b00m")
+		})
+
+		assert.EqualError(t, err, "b00m")
+	})
+}
+
+func TestCollection_ForEach(t *testing.T) {
+	_, c := testCollection(t)
+	_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+
+	t.Run("ok - all documents", func(t *testing.T) {
+		docs := map[string]Document{}
+
+		err := c.ForEach(context.Background(), func(ref Reference, doc []byte) error {
+			docs[ref.EncodeToString()] = doc
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, docs, 2)
+		assert.Equal(t, Document(exampleDoc), docs[c.Reference(exampleDoc).EncodeToString()])
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		err := c.ForEach(context.Background(), func(ref Reference, doc []byte) error {
+			return errors.New("b00m")
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - ctx cancelled", func(t *testing.T) {
+		ctx, cancelFn := context.WithCancel(context.Background())
+		count := 0
+
+		err := c.ForEach(ctx, func(ref Reference, doc []byte) error {
+			count++
+			cancelFn()
+			return nil
+		})
+
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := c.ForEach(context.Background(), func(ref Reference, doc []byte) error {
+			return errors.New("b00m")
+		})
+
+		assert.EqualError(t, err, "b00m")
+	})
+}
+
+func TestCollection_IndexIterate(t *testing.T) {
+	db, c, i := testIndex(t)
+	_ = c.AddIndex(i)
+	_ = c.Add(context.TODO(), []Document{exampleDoc})
+	q := New(Eq(NewJSONPath("path.part"), MustParseScalar("value")))
+
+	t.Run("ok - count fn", func(t *testing.T) {
+		count := 0
+
+		err := db.View(func(tx *bbolt.Tx) error {
+			return c.IndexIterate(context.Background(), q, func(key []byte, value []byte) error {
+				count++
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := db.View(func(tx *bbolt.Tx) error {
+			return c.IndexIterate(context.Background(), q, func(key []byte, value []byte) error {
+				return errors.New("b00m")
+			})
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		count := 0
+
+		err := c.IndexIterate(ctx, q, func(key []byte, value []byte) error {
+			count++
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestCollection_Reference(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		ref := c.Reference(exampleDoc)
+
+		assert.Equal(t, "d29cb76cae7662a142e36c85eb39f4caa7fa593f", ref.EncodeToString())
+	})
+
+	t.Run("ok - SHA-256", func(t *testing.T) {
+		_, c := testCollection(t)
+		c.refMake = SHA256ReferenceCreator
+
+		ref := c.Reference(exampleDoc)
+		expected := sha256.Sum256(exampleDoc)
+
+		assert.Equal(t, Reference(expected[:]), ref)
+	})
+}
+
+func TestCollection_MigrateDocuments(t *testing.T) {
+	// renameField moves the value "a" of "old" to "path.part"
+	renameField := func(doc Document) (Document, error) {
+		if !bytes.Contains(doc, []byte(`"old"`)) {
+			return nil, nil
+		}
+		return bytes.Replace(doc, []byte(`{"old": "a"}`), []byte(`{"path": {"part": "a"}}`), 1), nil
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		docs := []Document{
+			[]byte(`{"old": "a"}`),
+			[]byte(`{"path": {"part": "b"}}`),
+		}
+		_ = c.Add(context.TODO(), docs)
+		var progress [][2]int
+
+		err := c.MigrateDocuments(context.TODO(), renameField, WithProgress(func(migrated, total int) {
+			progress = append(progress, [2]int{migrated, total})
+		}))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		result, _ := c.Find(context.TODO(), New(Eq(NewJSONPath("path.part"), MustParseScalar("a"))))
+		assert.Equal(t, []Document{Document(`{"path": {"part": "a"}}`)}, result)
+		found, _ := c.Exists(c.Reference(docs[0]))
+		assert.False(t, found)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 2, count)
+		assert.Equal(t, [][2]int{{2, 2}}, progress)
+	})
+
+	t.Run("ok - unchanged documents are kept", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		ch := c.Watch(context.Background())
+
+		err := c.MigrateDocuments(context.TODO(), func(doc Document) (Document, error) {
+			return doc, nil
+		})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		found, _ := c.Exists(c.Reference(exampleDoc))
+		assert.True(t, found)
+		assert.Len(t, ch, 0)
+	})
+
+	t.Run("error - transformer fails, batch is rolled back", func(t *testing.T) {
+		_, c := testCollection(t)
+		docs := []Document{[]byte(`{"id": 1}`), []byte(`{"id": 2}`)}
+		_ = c.Add(context.TODO(), docs)
+		calls := 0
+
+		err := c.MigrateDocuments(context.TODO(), func(doc Document) (Document, error) {
+			calls++
+			if calls == 2 {
+				return nil, errors.New("b00m!")
+			}
+			return Document(`{"id": 3}`), nil
+		})
+
+		assert.ErrorContains(t, err, "b00m!")
+		for _, doc := range docs {
+			found, _ := c.Exists(c.Reference(doc))
+			assert.True(t, found)
+		}
+	})
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := c.MigrateDocuments(ctx, func(doc Document) (Document, error) {
+			return nil, nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCollection_CopyTo(t *testing.T) {
+	t.Run("ok - documents are copied to another store", func(t *testing.T) {
+		dir := testDirectory(t)
+		srcStore, _ := NewStore(filepath.Join(dir, "src.db"), WithoutSync())
+		dstStore, _ := NewStore(filepath.Join(dir, "dst.db"), WithoutSync())
+		defer srcStore.Close()
+		defer dstStore.Close()
+		src := srcStore.JSONCollection("credentials")
+		_ = src.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		dst := dstStore.JSONCollection("migrated")
+		_ = dst.AddIndex(dst.NewIndex("parts", NewFieldIndexer(NewJSONPath("path.parts"))))
+
+		err := src.CopyTo(dstStore, "migrated")
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		count, _ := dst.DocumentCount()
+		assert.Equal(t, 2, count)
+		docs, _ := dst.Find(context.TODO(), New(Eq(NewJSONPath("path.parts"), MustParseScalar("value2"))))
+		assert.Len(t, docs, 1)
+		assert.Equal(t, int64(0), dst.CollectionStats().FullTableScanCount)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		defer s.Close()
+
+		err := s.JSONCollection("src").CopyTo(s, "dst")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - same collection", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		defer s.Close()
+
+		err := s.JSONCollection("src").CopyTo(s, "src")
+
+		assert.EqualError(t, err, "source and destination collection must differ")
+	})
+}
+
+func TestCollection_Exists(t *testing.T) {
+	t.Run("ok - stored document", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		exists, err := c.Exists(c.Reference(exampleDoc))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, exists)
+		assert.Equal(t, int64(0), c.CollectionStats().DocumentsFetched)
+	})
+
+	t.Run("ok - unknown reference", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		exists, err := c.Exists([]byte("unknown"))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, exists)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		exists, err := c.Exists(c.Reference(exampleDoc))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, exists)
+	})
+}
+
+func TestCollection_Get(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		_, c := testCollection(t)
+		ref := defaultReferenceCreator(exampleDoc)
+		if err := c.Add(context.TODO(), []Document{exampleDoc}); err != nil {
+			t.Fatal(err)
+		}
+
+		d, found, err := c.Get(ref)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.True(t, found)
+		if assert.NotNil(t, d) {
+			assert.Equal(t, Document(exampleDoc), d)
+		}
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		d, found, err := c.Get([]byte("test"))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.False(t, found)
+		assert.Nil(t, d)
+	})
+
+	t.Run("ok - not found", func(t *testing.T) {
+		_, c := testCollection(t)
+		if err := c.Add(context.TODO(), []Document{exampleDoc}); err != nil {
+			t.Fatal(err)
+		}
+
+		d, found, err := c.Get([]byte("test"))
+
+		assert.NoError(t, err)
+		assert.False(t, found)
+		assert.Nil(t, d)
+	})
+
+	t.Run("error - document can't be decoded", func(t *testing.T) {
+		db, c := testCollection(t)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			bucket, _ := testBucket(t, tx).CreateBucketIfNotExists([]byte(documentCollection))
+			return bucket.Put([]byte("test"), []byte{snappyTag, 0xff})
+		})
+
+		d, found, err := c.Get([]byte("test"))
+
+		assert.Error(t, err)
+		assert.False(t, found)
+		assert.Nil(t, d)
+	})
+}
+
+func TestCollection_FindByReference(t *testing.T) {
+	ref1 := defaultReferenceCreator(exampleDoc)
+	ref2 := defaultReferenceCreator([]byte(jsonExample2))
+	missing := Reference("missing")
+
+	t.Run("ok - missing references are omitted", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+
+		docs, err := c.FindByReference(ref2, missing, ref1, ref2)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{[]byte(jsonExample2), exampleDoc}, docs)
+	})
+
+	t.Run("ok - missing references as placeholder", func(t *testing.T) {
+		_, c := testCollection(t)
+		c.missingPlaceholders = true
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		docs, err := c.FindByReference(missing, ref1, missing)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Document{nil, exampleDoc}, docs)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		docs, err := c.FindByReference(ref1)
+
+		assert.NoError(t, err)
+		assert.Empty(t, docs)
+	})
+}
+
+func TestCollection_GetMulti(t *testing.T) {
+	ref1 := defaultReferenceCreator(exampleDoc)
+	ref2 := defaultReferenceCreator([]byte(jsonExample2))
+	missing := Reference("missing")
+
+	t.Run("ok - missing references are absent", func(t *testing.T) {
+		_, c := testCollection(t)
+		c.missingPlaceholders = true
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+
+		docs, err := c.GetMulti([]Reference{ref2, missing, ref1, ref2})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, map[string]Document{
+			string(ref1): exampleDoc,
+			string(ref2): []byte(jsonExample2),
+		}, docs)
+	})
+
+	t.Run("ok - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		docs, err := c.GetMulti([]Reference{ref1})
+
+		assert.NoError(t, err)
+		assert.Empty(t, docs)
+	})
+}
+
+func TestCollection_GetOrAdd(t *testing.T) {
+	t.Run("ok - created", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		d, created, err := c.GetOrAdd(exampleDoc)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, created)
+		assert.Equal(t, Document(exampleDoc), d)
+		assertSize(t, db, documentCollection, 1)
+		assertIndexSize(t, db, i, 1)
+	})
+
+	t.Run("ok - existing", func(t *testing.T) {
+		db, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		d, created, err := c.GetOrAdd(exampleDoc)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, created)
+		assert.Equal(t, Document(exampleDoc), d)
+		assertSize(t, db, documentCollection, 1)
+	})
+
+	t.Run("error - indexing fails", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		_, created, err := c.GetOrAdd([]byte("}"))
+
+		assert.ErrorIs(t, err, ErrInvalidJSON)
+		assert.False(t, created)
+	})
+}
+
+func TestCollection_AddOrUpdate(t *testing.T) {
+	// references are created from the id, so both versions have the same reference
+	idReference := func(doc Document) Reference {
+		return Reference(doc[:9])
+	}
+	version1 := Document(`{"id":"1","path":{"part":"a"}}`)
+	version2 := Document(`{"id":"1","path":{"part":"b"}}`)
+
+	t.Run("ok - added", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		c.refMake = idReference
+		_ = c.AddIndex(i)
+
+		err := c.AddOrUpdate(version1)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assertSize(t, db, documentCollection, 1)
+		assertIndexSize(t, db, i, 1)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - replaced", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		c.refMake = idReference
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{version1})
+
+		err := c.AddOrUpdate(version2)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		stored, _, _ := c.Get(idReference(version1))
+		assert.Equal(t, version2, stored)
+		assertSize(t, db, documentCollection, 1)
+		assertIndexSize(t, db, i, 1)
+		old, _ := c.Find(context.TODO(), New(Eq(NewJSONPath("path.part"), MustParseScalar("a"))))
+		assert.Empty(t, old)
+		current, _ := c.Find(context.TODO(), New(Eq(NewJSONPath("path.part"), MustParseScalar("b"))))
+		assert.Len(t, current, 1)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error - indexing fails, stored document is kept", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		c.refMake = idReference
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{version1})
+
+		err := c.AddOrUpdate(Document(`{"id":"1"`))
+
+		assert.ErrorIs(t, err, ErrInvalidJSON)
+		stored, _, _ := c.Get(idReference(version1))
+		assert.Equal(t, version1, stored)
+	})
+}
 
-		docs, err := c.Find(context.TODO(), q)
+func TestCollection_SetDocumentLoader(t *testing.T) {
+	t.Run("ok - overrides loader of the store", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		c := s.JSONLDCollection("test")
+		other := s.JSONLDCollection("other")
 
-		if !assert.NoError(t, err) {
-			return
-		}
+		c.SetDocumentLoader(testDocumentLoader{})
 
-		assert.Len(t, docs, 1)
+		_, ok := c.DocumentLoader().(testDocumentLoader)
+		assert.True(t, ok)
+		_, ok = other.DocumentLoader().(testDocumentLoader)
+		assert.False(t, ok)
 	})
+}
 
-	t.Run("ok - with ResultScan", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value"))).And(Eq(nonIndexed, MustParseScalar("value")))
+func TestCollection_Metadata(t *testing.T) {
+	t.Run("ok - set and get", func(t *testing.T) {
+		_, c := testCollection(t)
 
-		docs, err := c.Find(context.TODO(), q)
+		err := c.SetMetadata("schemaVersion", "2")
 
 		if !assert.NoError(t, err) {
 			return
 		}
+		value, err := c.GetMetadata("schemaVersion")
+		assert.NoError(t, err)
+		assert.Equal(t, "2", value)
+	})
 
-		assert.Len(t, docs, 1)
+	t.Run("ok - overwrite", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.SetMetadata("lastSync", "yesterday")
+
+		_ = c.SetMetadata("lastSync", "today")
+
+		value, _ := c.GetMetadata("lastSync")
+		assert.Equal(t, "today", value)
 	})
 
-	t.Run("ok - with Full table scan", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(nonIndexed, MustParseScalar("value")))
+	t.Run("ok - all metadata", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.SetMetadata("a", "1")
+		_ = c.SetMetadata("b", "2")
 
-		docs, err := c.Find(context.TODO(), q)
+		all, err := c.AllMetadata()
 
-		if !assert.NoError(t, err) {
-			return
-		}
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "1", "b": "2"}, all)
+	})
 
-		assert.Len(t, docs, 1)
+	t.Run("ok - all metadata of empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		all, err := c.AllMetadata()
+
+		assert.NoError(t, err)
+		assert.Empty(t, all)
 	})
 
-	t.Run("ok - with ResultScan and range query", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value"))).And(Range(nonIndexed, MustParseScalar("v"), MustParseScalar("value1")))
+	t.Run("error - not found", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.SetMetadata("a", "1")
 
-		docs, err := c.Find(context.TODO(), q)
+		_, err := c.GetMetadata("b")
 
-		if !assert.NoError(t, err) {
-			return
-		}
+		assert.ErrorIs(t, err, ErrMetadataNotFound)
+	})
 
-		assert.Len(t, docs, 1)
+	t.Run("error - empty collection", func(t *testing.T) {
+		_, c := testCollection(t)
+
+		_, err := c.GetMetadata("a")
+
+		assert.ErrorIs(t, err, ErrMetadataNotFound)
 	})
 
-	t.Run("ok - with ResultScan, range query not found", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value"))).And(
-			Range(nonIndexed, MustParseScalar("value1"), MustParseScalar("value2")))
+	t.Run("error - reserved key", func(t *testing.T) {
+		_, c := testCollection(t)
 
-		docs, err := c.Find(context.TODO(), q)
+		err := c.SetMetadata("_leia.schemaVersion", "1")
+
+		assert.ErrorIs(t, err, ErrReservedMetadataKey)
+	})
+}
+
+func TestCollection_DocumentCount(t *testing.T) {
+	t.Run("ok - 1 entry", func(t *testing.T) {
+		_, c := testCollection(t)
+		if err := c.Add(context.TODO(), []Document{exampleDoc}); err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := c.DocumentCount()
 
 		if !assert.NoError(t, err) {
 			return
 		}
-
-		assert.Len(t, docs, 0)
+		assert.Equal(t, 1, count)
 	})
 
-	t.Run("ok - no docs", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		q := New(Eq(key, MustParseScalar("value")))
+	t.Run("ok - empty", func(t *testing.T) {
+		_, c := testCollection(t)
 
-		docs, err := c.Find(context.TODO(), q)
+		count, err := c.DocumentCount()
 
 		if !assert.NoError(t, err) {
 			return
 		}
-
-		assert.Len(t, docs, 0)
+		assert.Equal(t, 0, count)
 	})
 
-	t.Run("error - ctx cancelled", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value")))
-		ctx, cancelFn := context.WithCancel(context.Background())
+	t.Run("ok - count is kept in memory", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_, _ = c.DocumentCount()
 
-		cancelFn()
-		_, err := c.Find(ctx, q)
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		_, _, _ = c.GetOrAdd(Document(`{"id": 1}`))
+		_ = c.AddConcurrent([]Document{Document(`{"id": 2}`)})
+		_ = c.Delete(exampleDoc)
+		_ = c.Delete(exampleDoc)
 
-		if !assert.Error(t, err) {
+		count, err := c.DocumentCount()
+
+		if !assert.NoError(t, err) {
 			return
 		}
-
-		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 3, count)
+		stored, _ := c.countDocuments()
+		assert.Equal(t, stored, count)
 	})
 
-	t.Run("error - deadline exceeded", func(t *testing.T) {
-		_, c, i := testIndex(t)
-		_ = c.AddIndex(i)
-		_ = c.Add([]Document{exampleDoc})
-		q := New(Eq(key, MustParseScalar("value")))
-		ctx, _ := context.WithTimeout(context.Background(), time.Nanosecond)
+	t.Run("ok - exact count sees documents added by another collection instance", func(t *testing.T) {
+		db, c := testCollection(t)
+		c.exactCount = true
+		_, _ = c.DocumentCount()
 
-		_, err := c.Find(ctx, q)
+		_ = testCollectionWithDB(db).Add(context.TODO(), []Document{exampleDoc})
+		count, err := c.DocumentCount()
 
-		if !assert.Error(t, err) {
+		if !assert.NoError(t, err) {
 			return
 		}
-
-		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Equal(t, 1, count)
 	})
 }
 
-func TestCollection_Iterate(t *testing.T) {
+func TestCollection_CollectionStats(t *testing.T) {
 	key := NewJSONPath("path.part")
-
 	_, c, i := testIndex(t)
 	_ = c.AddIndex(i)
-	_ = c.Add([]Document{exampleDoc})
-	q := New(Eq(key, MustParseScalar("value")))
+	_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
 
-	t.Run("ok - count fn", func(t *testing.T) {
-		count := 0
+	t.Run("ok - counts read operations", func(t *testing.T) {
+		c.ResetStats()
 
-		err := c.Iterate(q, func(key Reference, value []byte) error {
-			count++
+		_, _ = c.Find(context.Background(), New(Eq(key, MustParseScalar("value"))))
+		_ = c.Iterate(New(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))), func(key Reference, value []byte) error {
 			return nil
 		})
-
-		assert.NoError(t, err)
-		assert.Equal(t, 1, count)
+		_, _, _ = c.Get(c.Reference(exampleDoc))
+
+		stats := c.CollectionStats()
+		assert.Equal(t, int64(1), stats.FindCount)
+		assert.Equal(t, int64(1), stats.IterateCount)
+		assert.Equal(t, int64(1), stats.GetCount)
+		assert.Equal(t, int64(1), stats.IndexScanCount)
+		assert.Equal(t, int64(1), stats.FullTableScanCount)
+		// 2 by index, 2 by full table scan and 1 by get
+		assert.Equal(t, int64(5), stats.DocumentsFetched)
 	})
 
-	t.Run("ok - document indexed multiple times, query should un double", func(t *testing.T) {
-		doc := []byte(jsonExample)
-		doc2 := []byte(jsonExample2)
-		count := 0
+	t.Run("ok - reset", func(t *testing.T) {
+		_, _, _ = c.Get(c.Reference(exampleDoc))
 
-		i := c.NewIndex(t.Name(),
-			NewFieldIndexer(NewJSONPath("path.part")),
-			NewFieldIndexer(NewJSONPath("path.more.#.parts")),
-		)
+		c.ResetStats()
 
-		_, c := testCollection(t)
+		assert.Equal(t, CollectionStats{}, c.CollectionStats())
+	})
+}
+
+func TestCollection_AddHook(t *testing.T) {
+	key := NewJSONPath("path.part")
+
+	t.Run("ok - hooks are called after operations", func(t *testing.T) {
+		_, c, i := testIndex(t)
 		_ = c.AddIndex(i)
-		_ = c.Add([]Document{doc, doc2})
+		events := map[EventType][]EventData{}
+		for _, event := range []EventType{EventAdd, EventDelete, EventFind, EventTableScan, EventIndexScan} {
+			event := event
+			c.AddHook(event, func(data EventData) {
+				events[event] = append(events[event], data)
+			})
+		}
 
-		err := c.Iterate(q, func(key Reference, value []byte) error {
-			count++
-			return nil
-		})
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		_, _ = c.Find(context.Background(), New(Eq(key, MustParseScalar("value"))))
+		_, _ = c.Find(context.Background(), New(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))))
+		_ = c.Delete(exampleDoc)
 
-		assert.NoError(t, err)
-		assert.Equal(t, 2, count)
+		if assert.Len(t, events[EventAdd], 1) {
+			assert.Equal(t, "test", events[EventAdd][0].Collection)
+			assert.Equal(t, 2, events[EventAdd][0].DocCount)
+		}
+		if assert.Len(t, events[EventFind], 2) {
+			assert.Equal(t, 2, events[EventFind][0].DocCount)
+			assert.Equal(t, 1, events[EventFind][1].DocCount)
+		}
+		if assert.Len(t, events[EventIndexScan], 1) {
+			assert.Equal(t, 2, events[EventIndexScan][0].DocCount)
+		}
+		if assert.Len(t, events[EventTableScan], 1) {
+			assert.Equal(t, 1, events[EventTableScan][0].DocCount)
+		}
+		if assert.Len(t, events[EventDelete], 1) {
+			assert.Equal(t, c.Reference(exampleDoc), events[EventDelete][0].Ref)
+		}
 	})
 
-	t.Run("error", func(t *testing.T) {
-		err := c.Iterate(q, func(key Reference, value []byte) error {
-			return errors.New("b00m")
+	t.Run("ok - documents that were already stored aren't counted", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		var counts []int
+		c.AddHook(EventAdd, func(data EventData) {
+			counts = append(counts, data.DocCount)
 		})
 
-		assert.Error(t, err)
-	})
-}
-
-func TestCollection_IndexIterate(t *testing.T) {
-	db, c, i := testIndex(t)
-	_ = c.AddIndex(i)
-	_ = c.Add([]Document{exampleDoc})
-	q := New(Eq(NewJSONPath("path.part"), MustParseScalar("value")))
+		_ = c.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		_ = c.AddConcurrent([]Document{exampleDoc})
 
-	t.Run("ok - count fn", func(t *testing.T) {
-		count := 0
+		assert.Equal(t, []int{1, 0}, counts)
+	})
 
-		err := db.View(func(tx *bbolt.Tx) error {
-			return c.IndexIterate(q, func(key []byte, value []byte) error {
-				count++
-				return nil
-			})
+	t.Run("ok - deleting a missing document isn't counted", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		var counts []int
+		c.AddHook(EventDelete, func(data EventData) {
+			counts = append(counts, data.DocCount)
 		})
 
-		assert.NoError(t, err)
-		assert.Equal(t, 1, count)
+		_ = c.Delete(exampleDoc)
+		_ = c.Delete(exampleDoc)
+
+		assert.Equal(t, []int{1, 0}, counts)
 	})
 
-	t.Run("error", func(t *testing.T) {
-		err := db.View(func(tx *bbolt.Tx) error {
-			return c.IndexIterate(q, func(key []byte, value []byte) error {
-				return errors.New("b00m")
-			})
+	t.Run("ok - not called on error", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+		called := false
+		c.AddHook(EventAdd, func(data EventData) {
+			called = true
 		})
 
-		assert.Error(t, err)
+		_ = c.Add(context.TODO(), []Document{[]byte("}")})
+
+		assert.False(t, called)
 	})
-}
 
-func TestCollection_Reference(t *testing.T) {
-	t.Run("ok", func(t *testing.T) {
+	t.Run("ok - RemoveHooks", func(t *testing.T) {
 		_, c := testCollection(t)
+		called := false
+		c.AddHook(EventAdd, func(data EventData) {
+			called = true
+		})
 
-		ref := c.Reference(exampleDoc)
+		c.RemoveHooks(EventAdd)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 
-		assert.Equal(t, "d29cb76cae7662a142e36c85eb39f4caa7fa593f", ref.EncodeToString())
+		assert.False(t, called)
 	})
 }
 
-func TestCollection_Get(t *testing.T) {
-	t.Run("ok", func(t *testing.T) {
+func TestCollection_Watch(t *testing.T) {
+	t.Run("ok - added and deleted documents are sent", func(t *testing.T) {
 		_, c := testCollection(t)
-		ref := defaultReferenceCreator(exampleDoc)
-		if err := c.Add([]Document{exampleDoc}); err != nil {
-			t.Fatal(err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := c.Watch(ctx)
+		doc2 := Document(jsonExample2)
+
+		_ = c.Add(context.TODO(), []Document{exampleDoc, doc2})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_, _ = c.DeleteWhere(context.TODO(), New(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))))
+		_ = c.Delete(doc2)
+		_ = c.Delete(doc2)
+
+		expected := []DocumentEvent{
+			{Op: OpAdd, Reference: c.Reference(exampleDoc), Document: exampleDoc},
+			{Op: OpAdd, Reference: c.Reference(doc2), Document: doc2},
+			{Op: OpDelete, Reference: c.Reference(exampleDoc), Document: exampleDoc},
+			{Op: OpDelete, Reference: c.Reference(doc2), Document: doc2},
 		}
+		for _, e := range expected {
+			assert.Equal(t, e, <-events)
+		}
+		assert.Len(t, events, 0)
+	})
 
-		d, err := c.Get(ref)
+	t.Run("ok - all subscribers receive the events", func(t *testing.T) {
+		_, c := testCollection(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events1 := c.Watch(ctx)
+		events2 := c.Watch(ctx)
 
-		if !assert.NoError(t, err) {
-			return
-		}
+		_, _, _ = c.GetOrAdd(exampleDoc)
 
-		if assert.NotNil(t, d) {
-			assert.Equal(t, Document(exampleDoc), d)
-		}
+		assert.Equal(t, OpAdd, (<-events1).Op)
+		assert.Equal(t, OpAdd, (<-events2).Op)
 	})
 
-	t.Run("error - not found", func(t *testing.T) {
+	t.Run("ok - channel is closed when the context is cancelled", func(t *testing.T) {
 		_, c := testCollection(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		events := c.Watch(ctx)
 
-		d, err := c.Get([]byte("test"))
-
-		if !assert.NoError(t, err) {
-			return
-		}
+		cancel()
+		_, ok := <-events
 
-		assert.Nil(t, d)
+		assert.False(t, ok)
+		c.watchers.mutex.RLock()
+		defer c.watchers.mutex.RUnlock()
+		assert.Empty(t, c.watchers.subscribers)
 	})
-}
 
-func TestCollection_DocumentCount(t *testing.T) {
-	t.Run("ok - 1 entry", func(t *testing.T) {
+	t.Run("ok - writers aren't blocked by a cancelled subscriber", func(t *testing.T) {
 		_, c := testCollection(t)
-		if err := c.Add([]Document{exampleDoc}); err != nil {
-			t.Fatal(err)
+		ctx, cancel := context.WithCancel(context.Background())
+		_ = c.Watch(ctx)
+		docs := make([]Document, watchBufferSize+1)
+		for j := range docs {
+			docs[j] = []byte(fmt.Sprintf(`{"id": %d}`, j))
 		}
+		cancel()
 
-		count, err := c.DocumentCount()
+		err := c.Add(context.TODO(), docs)
 
-		if !assert.NoError(t, err) {
-			return
-		}
-		assert.Equal(t, 1, count)
+		assert.NoError(t, err)
 	})
 
-	t.Run("ok - empty", func(t *testing.T) {
+	t.Run("ok - subscriber can query while an index is added", func(t *testing.T) {
 		_, c := testCollection(t)
-
-		count, err := c.DocumentCount()
-
-		if !assert.NoError(t, err) {
-			return
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := c.Watch(ctx)
+		docs := make([]Document, 200)
+		for j := range docs {
+			docs[j] = []byte(fmt.Sprintf(`{"id": %d}`, j))
+		}
+		indexAdded := make(chan error, 1)
+		go func() {
+			first := true
+			for range events {
+				if first {
+					// AddIndex waits for the write lock while the documents are added
+					first = false
+					go func() {
+						indexAdded <- c.AddIndex(c.NewIndex("by_id", NewFieldIndexer(NewJSONPath("id"))))
+					}()
+					time.Sleep(10 * time.Millisecond)
+				}
+				_, _ = c.Find(context.TODO(), New(Eq(NewJSONPath("id"), MustParseScalar(1))))
+			}
+		}()
+		added := make(chan error, 1)
+		go func() {
+			added <- c.Add(context.TODO(), docs)
+		}()
+
+		for _, done := range []chan error{added, indexAdded} {
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("deadlock between the writer, the subscriber and AddIndex")
+			}
 		}
-		assert.Equal(t, 0, count)
 	})
 }
 
@@ -469,6 +3060,16 @@ func TestCollection_JSONPathValueCollector(t *testing.T) {
 		assert.Equal(t, 1.0, values[0].value())
 	})
 
+	t.Run("ok - integer beyond float64 precision", func(t *testing.T) {
+		values, err := c.ValuesAtPath([]byte(`{"sequence": 9007199254740993}`), NewJSONPath("sequence"))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Scalar{Int64Scalar(9007199254740993)}, values)
+	})
+
 	t.Run("ok - find a single string value", func(t *testing.T) {
 		values, err := c.ValuesAtPath(json, NewJSONPath("path"))
 
@@ -504,6 +3105,18 @@ func TestCollection_JSONPathValueCollector(t *testing.T) {
 		assert.Equal(t, "bike", values[1].value())
 	})
 
+	t.Run("ok - modifier", func(t *testing.T) {
+		values, err := c.ValuesAtPath(json, NewJSONPath("colors.@reverse"))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, values, 2)
+		assert.Equal(t, "orange", values[0].value())
+		assert.Equal(t, "blue", values[1].value())
+	})
+
 	t.Run("ok - values at an unknown path", func(t *testing.T) {
 		values, err := c.ValuesAtPath(json, NewJSONPath("unknown"))
 
@@ -624,6 +3237,32 @@ func TestCollection_JSONLDValueCollector(t *testing.T) {
 		assert.Equal(t, "http://example.com/Person", values[0].value())
 	})
 
+	t.Run("ok - find nested values with a wildcard", func(t *testing.T) {
+		values, err := c.ValuesAtPath(document, IRIPathWildcard(nil, []string{"http://example.com/url"}))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// children and parents
+		assert.Len(t, values, 2)
+		assert.Equal(t, "http://www.johndoe.org", values[0].value())
+		assert.Equal(t, "http://www.johndoe.org", values[1].value())
+	})
+
+	t.Run("ok - find values with a wildcard at the end", func(t *testing.T) {
+		values, err := c.ValuesAtPath(document, IRIPathWildcard([]string{"http://example.com/parents"}, nil))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// name and url of the parent, @type is skipped
+		assert.Len(t, values, 2)
+		assert.Equal(t, "John Doe", values[0].value())
+		assert.Equal(t, "http://www.johndoe.org", values[1].value())
+	})
+
 	t.Run("ok - empty for incomplete path", func(t *testing.T) {
 		values, err := c.ValuesAtPath(document, NewIRIPath("http://example.com/children"))
 
@@ -639,15 +3278,16 @@ func TestJSONPathValueCollector(t *testing.T) {
 	t.Run("error - invalid QueryPath type", func(t *testing.T) {
 		_, err := JSONPathValueCollector(nil, Document{}, NewIRIPath())
 
-		assert.Equal(t, ErrInvalidQuery, err)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
 	})
 }
 
 func TestJSONLDValueCollector(t *testing.T) {
 	t.Run("error - invalid QueryPath type", func(t *testing.T) {
-		_, err := JSONLDValueCollector(nil, Document{}, NewJSONPath("."))
+		_, err := JSONLDValueCollector(nil, Document{}, NewJSONPath("path.part"))
 
-		assert.Equal(t, ErrInvalidQuery, err)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+		assert.EqualError(t, err, "invalid query type: path.part is not an IRI path")
 	})
 }
 
@@ -667,10 +3307,11 @@ func testCollection(t *testing.T) (*bbolt.DB, *collection) {
 
 func testCollectionWithDB(db *bbolt.DB) *collection {
 	return &collection{
-		name:           "test",
-		db:             db,
-		indexList:      []Index{},
-		refMake:        defaultReferenceCreator,
-		valueCollector: JSONPathValueCollector,
+		name:               "test",
+		db:                 newBoltDB(db),
+		indexList:          []Index{},
+		refMake:            defaultReferenceCreator,
+		documentBucketName: documentCollection,
+		valueCollector:     JSONPathValueCollector,
 	}
 }
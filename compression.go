@@ -0,0 +1,131 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/pierrec/lz4/v4"
+)
+
+// CompressionCodec compresses documents before they are stored and decompresses them when they are read.
+// Only the codecs provided by this package can be used: NoOpCodec, SnappyCodec and LZ4Codec.
+type CompressionCodec interface {
+	// Compress returns the compressed data
+	Compress(data []byte) ([]byte, error)
+	// Decompress returns the original data
+	Decompress(data []byte) ([]byte, error)
+	// tag identifies the codec, it's prepended to compressed documents. 0 means the document is stored as is.
+	tag() byte
+}
+
+// codec tags, these bytes can't be the first byte of a JSON document
+const (
+	noOpTag   byte = 0x00
+	snappyTag byte = 0x01
+	lz4Tag    byte = 0x02
+)
+
+// codecs contains the codecs by tag that are used to decompress a document
+var codecs = map[byte]CompressionCodec{
+	snappyTag: SnappyCodec{},
+	lz4Tag:    LZ4Codec{},
+}
+
+// NoOpCodec stores documents uncompressed. This is the default.
+type NoOpCodec struct{}
+
+func (n NoOpCodec) Compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (n NoOpCodec) Decompress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (n NoOpCodec) tag() byte {
+	return noOpTag
+}
+
+// SnappyCodec compresses documents using Snappy
+type SnappyCodec struct{}
+
+func (s SnappyCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (s SnappyCodec) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+func (s SnappyCodec) tag() byte {
+	return snappyTag
+}
+
+// LZ4Codec compresses documents using the LZ4 frame format
+type LZ4Codec struct{}
+
+func (l LZ4Codec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := lz4.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (l LZ4Codec) Decompress(data []byte) ([]byte, error) {
+	return io.ReadAll(lz4.NewReader(bytes.NewReader(data)))
+}
+
+func (l LZ4Codec) tag() byte {
+	return lz4Tag
+}
+
+// encodeDocument compresses the document with the given codec and prepends the tag of the codec.
+// Documents are stored as is when no compression is used.
+func encodeDocument(codec CompressionCodec, doc Document) ([]byte, error) {
+	if codec == nil || codec.tag() == noOpTag {
+		return doc, nil
+	}
+	compressed, err := codec.Compress(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{codec.tag()}, compressed...), nil
+}
+
+// decodeDocument decompresses stored data using the codec identified by the first byte.
+// Data without a known tag is returned as is, so collections may contain documents stored with different codecs.
+func decodeDocument(data []byte) (Document, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	codec, ok := codecs[data[0]]
+	if !ok {
+		return data, nil
+	}
+	return codec.Decompress(data[1:])
+}
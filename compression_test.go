@@ -0,0 +1,83 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionCodecs(t *testing.T) {
+	codecs := map[string]CompressionCodec{
+		"noop":   NoOpCodec{},
+		"snappy": SnappyCodec{},
+		"lz4":    LZ4Codec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run("ok - "+name+" round trip", func(t *testing.T) {
+			data, err := encodeDocument(codec, exampleDoc)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			doc, err := decodeDocument(data)
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, Document(exampleDoc), doc)
+		})
+	}
+}
+
+func TestEncodeDocument(t *testing.T) {
+	t.Run("ok - stored as is without codec", func(t *testing.T) {
+		data, err := encodeDocument(nil, exampleDoc)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(exampleDoc), data)
+	})
+
+	t.Run("ok - tag is prepended", func(t *testing.T) {
+		data, err := encodeDocument(SnappyCodec{}, exampleDoc)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, snappyTag, data[0])
+	})
+}
+
+func TestDecodeDocument(t *testing.T) {
+	t.Run("ok - untagged document", func(t *testing.T) {
+		doc, err := decodeDocument(exampleDoc)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Document(exampleDoc), doc)
+	})
+
+	t.Run("error - corrupt data", func(t *testing.T) {
+		_, err := decodeDocument([]byte{snappyTag, 0xff, 0xff, 0xff})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+/*
+ * go-leia
+ * Copyright (C) 2021 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// ErrStoreUnusable is returned by all operations after Store.Vacuum failed to reopen the bbolt file.
+// The store must be closed and opened again with NewStore.
+var ErrStoreUnusable = errors.New("store is unusable, the bbolt file couldn't be reopened")
+
+// boltDB holds the bbolt DB that is shared by the store and its collections.
+// Transactions hold the read lock, so the DB can be replaced by Store.Vacuum while holding the write lock.
+type boltDB struct {
+	lock sync.RWMutex
+	db   *bbolt.DB
+	// err is set when the DB couldn't be reopened, it's returned by all transactions
+	err error
+}
+
+func newBoltDB(db *bbolt.DB) *boltDB {
+	return &boltDB{db: db}
+}
+
+// View runs fn in a read-only transaction, see bbolt.DB.View
+func (b *boltDB) View(fn func(tx *bbolt.Tx) error) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.err != nil {
+		return b.err
+	}
+	return b.db.View(fn)
+}
+
+// Update runs fn in a read-write transaction, see bbolt.DB.Update
+func (b *boltDB) Update(fn func(tx *bbolt.Tx) error) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.err != nil {
+		return b.err
+	}
+	return b.db.Update(fn)
+}
+
+// Batch runs fn as part of a batch, see bbolt.DB.Batch
+func (b *boltDB) Batch(fn func(tx *bbolt.Tx) error) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.err != nil {
+		return b.err
+	}
+	return b.db.Batch(fn)
+}
+
+// replace calls fn with the current DB while no transaction is running, the DB returned by fn replaces the current one.
+// When fn returns an error without a DB, the DB is marked unusable.
+func (b *boltDB) replace(fn func(current *bbolt.DB) (*bbolt.DB, error)) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	db, err := fn(b.db)
+	if db == nil {
+		b.db = nil
+		b.err = fmt.Errorf("%w: %w", ErrStoreUnusable, err)
+		return b.err
+	}
+	b.db = db
+	return err
+}
+
+// Close closes the bbolt DB, it waits for running transactions
+func (b *boltDB) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
@@ -0,0 +1,81 @@
+/*
+ * go-leia
+ * Copyright (C) 2021 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func TestBoltDB_replace(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		b := newBoltDB(testDB(t))
+		other, err := bbolt.Open(filepath.Join(testDirectory(t), "other.db"), boltDBFileMode, &bbolt.Options{NoSync: true})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer b.Close()
+
+		err = b.replace(func(current *bbolt.DB) (*bbolt.DB, error) {
+			return other, current.Close()
+		})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Same(t, other, b.db)
+		assert.NoError(t, b.View(func(tx *bbolt.Tx) error { return nil }))
+	})
+
+	t.Run("error - current DB is kept", func(t *testing.T) {
+		db := testDB(t)
+		b := newBoltDB(db)
+		defer b.Close()
+
+		err := b.replace(func(current *bbolt.DB) (*bbolt.DB, error) {
+			return current, errors.New("b00m")
+		})
+
+		assert.EqualError(t, err, "b00m")
+		assert.Same(t, db, b.db)
+		assert.NoError(t, b.Update(func(tx *bbolt.Tx) error { return nil }))
+	})
+
+	t.Run("error - unusable without a DB", func(t *testing.T) {
+		b := newBoltDB(testDB(t))
+
+		err := b.replace(func(current *bbolt.DB) (*bbolt.DB, error) {
+			_ = current.Close()
+			return nil, errors.New("b00m")
+		})
+
+		assert.ErrorIs(t, err, ErrStoreUnusable)
+		assert.ErrorContains(t, err, "b00m")
+		assert.ErrorIs(t, b.View(func(tx *bbolt.Tx) error { return nil }), ErrStoreUnusable)
+		assert.ErrorIs(t, b.Update(func(tx *bbolt.Tx) error { return nil }), ErrStoreUnusable)
+		assert.ErrorIs(t, b.Batch(func(tx *bbolt.Tx) error { return nil }), ErrStoreUnusable)
+		assert.ErrorIs(t, b.replace(func(current *bbolt.DB) (*bbolt.DB, error) { return current, nil }), ErrStoreUnusable)
+		assert.NoError(t, b.Close())
+	})
+}
@@ -0,0 +1,123 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+
+	"github.com/nuts-foundation/go-leia/v4"
+)
+
+// coordinateRange is a custom leia.QueryPart that matches a coordinate between min and max (inclusive).
+// Float64Scalars are stored as IEEE 754 bits, so negative values don't sort in numeric order.
+// The condition decodes the value instead of comparing bytes.
+// A non-contiguous match like this can't be resolved using an index, so it's used on non-indexed fields.
+type coordinateRange struct {
+	queryPath leia.QueryPath
+	min       float64
+	max       float64
+}
+
+func (c coordinateRange) Equals(other leia.QueryPathComparable) bool {
+	return c.queryPath.Equals(other.QueryPath())
+}
+
+func (c coordinateRange) QueryPath() leia.QueryPath {
+	return c.queryPath
+}
+
+func (c coordinateRange) Seek() leia.Scalar {
+	return leia.StringScalar("")
+}
+
+func (c coordinateRange) Condition(key leia.Key, _ leia.Transform) bool {
+	if len(key) != 8 {
+		return false
+	}
+	value := math.Float64frombits(binary.BigEndian.Uint64(key))
+	return value >= c.min && value <= c.max
+}
+
+// boundingBox creates a query that matches the documents with a location.lat and location.lon within the given box
+func boundingBox(minLat, minLon, maxLat, maxLon float64) leia.Query {
+	return leia.New(coordinateRange{queryPath: leia.NewJSONPath("location.lat"), min: minLat, max: maxLat}).
+		And(coordinateRange{queryPath: leia.NewJSONPath("location.lon"), min: minLon, max: maxLon})
+}
+
+var placeTemplate = `
+{
+	"name": "%s",
+	"location": {
+		"lat": %f,
+		"lon": %f
+	}
+}
+`
+
+func main() {
+	dir, err := ioutil.TempDir("", "geo")
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("Unable to remove temporary directory (%s): %v\n", dir, err))
+		}
+	}()
+
+	s, err := leia.NewStore(path.Join(dir, "documents.db"))
+	if err != nil {
+		panic(err)
+	}
+	c, err := s.OpenCollection(leia.JSONCollection, "places")
+	if err != nil {
+		panic(err)
+	}
+
+	err = c.Add(context.Background(), []leia.Document{
+		leia.Document(fmt.Sprintf(placeTemplate, "Amsterdam", 52.37, 4.90)),
+		leia.Document(fmt.Sprintf(placeTemplate, "Rotterdam", 51.92, 4.48)),
+		leia.Document(fmt.Sprintf(placeTemplate, "Buenos Aires", -34.60, -58.38)),
+		leia.Document(fmt.Sprintf(placeTemplate, "Montevideo", -34.90, -56.16)),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// the Netherlands
+	docs, err := c.Find(context.Background(), boundingBox(50.75, 3.36, 53.55, 7.23))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("found %d places in the Netherlands\n", len(docs))
+
+	// Río de la Plata
+	docs, err = c.Find(context.Background(), boundingBox(-35.5, -59.0, -34.0, -55.5))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("found %d places around the Río de la Plata\n", len(docs))
+}
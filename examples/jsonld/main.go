@@ -74,7 +74,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	c := s.Collection(leia.JSONLDCollection, "json")
+	c, err := s.OpenCollection(leia.JSONLDCollection, "json")
+	if err != nil {
+		panic(err)
+	}
 	var compoundIndex = c.NewIndex("compound",
 		leia.NewFieldIndexer(leia.NewIRIPath("http://example.com/name"), leia.TransformerOption(leia.ToLower)),
 		leia.NewFieldIndexer(leia.NewIRIPath("http://example.com/url")),
@@ -96,7 +99,7 @@ func main() {
 				}
 			}
 		}
-		err = c.Add(docs)
+		err = c.Add(context.Background(), docs)
 		if err != nil {
 			panic(err)
 		}
@@ -44,7 +44,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	c := s.Collection(leia.JSONCollection, "json")
+	c, err := s.OpenCollection(leia.JSONCollection, "json")
+	if err != nil {
+		panic(err)
+	}
 	var compoundIndex = c.NewIndex("compound",
 		leia.NewFieldIndexer(leia.NewJSONPath("id"), leia.TokenizerOption(leia.WhiteSpaceTokenizer), leia.TransformerOption(leia.ToLower)),
 		leia.NewFieldIndexer(leia.NewJSONPath("obj.key"), leia.TokenizerOption(leia.WhiteSpaceTokenizer)),
@@ -67,7 +70,7 @@ func main() {
 				}
 			}
 		}
-		err = c.Add(docs)
+		err = c.Add(context.Background(), docs)
 		if err != nil {
 			panic(err)
 		}
@@ -87,7 +90,7 @@ func main() {
 	}
 	fmt.Printf("found %d docs\n", len(j))
 	i := 0
-	c.IndexIterate(query, func(key []byte, value []byte) error {
+	c.IndexIterate(context.Background(), query, func(key []byte, value []byte) error {
 		i++
 		return nil
 	})
@@ -106,7 +109,7 @@ func main() {
 	fmt.Printf("found %d docs\n", len(j))
 	i = 0
 
-	c.IndexIterate(query2, func(key []byte, value []byte) error {
+	c.IndexIterate(context.Background(), query2, func(key []byte, value []byte) error {
 		i++
 		return nil
 	})
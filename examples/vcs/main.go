@@ -46,7 +46,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	c := s.Collection(leia.JSONCollection, "vcs")
+	c, err := s.OpenCollection(leia.JSONCollection, "vcs")
+	if err != nil {
+		panic(err)
+	}
 	var credentialIndex = c.NewIndex("subject.resource",
 		leia.NewFieldIndexer(leia.NewJSONPath("credentialSubject.id")),
 		leia.NewFieldIndexer(leia.NewJSONPath("credentialSubject.resources.#.path"), leia.TransformerOption(leia.ToLower)),
@@ -122,7 +125,7 @@ func genJson(issuers, subjects, total int, collection leia.Collection) {
 
 				startDate = startDate.AddDate(0, 0, 1)
 			}
-			err := collection.Add(docs)
+			err := collection.Add(context.Background(), docs)
 			if err != nil {
 				panic(err)
 			}
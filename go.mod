@@ -5,10 +5,13 @@ go 1.21
 toolchain go1.21.10
 
 require (
+	github.com/golang/snappy v0.0.4
+	github.com/pierrec/lz4/v4 v4.1.21
 	github.com/piprate/json-gold v0.4.1
 	github.com/stretchr/testify v1.8.4
 	github.com/tidwall/gjson v1.17.0
 	go.etcd.io/bbolt v1.3.8
+	golang.org/x/text v0.14.0
 )
 
 require (
@@ -17,6 +20,6 @@ require (
 	github.com/pquerna/cachecontrol v0.0.0-20180517163645-1555304b9b35 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
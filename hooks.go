@@ -0,0 +1,87 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType defines the operation for which a hook is called
+type EventType int
+
+const (
+	// EventAdd is emitted after documents have been added
+	EventAdd EventType = iota
+	// EventDelete is emitted after a document has been deleted
+	EventDelete
+	// EventFind is emitted after a Find has completed
+	EventFind
+	// EventTableScan is emitted after a query has been executed without an index
+	EventTableScan
+	// EventIndexScan is emitted after a query has been executed using an index
+	EventIndexScan
+)
+
+// EventData contains the details of an operation that are passed to a hook
+type EventData struct {
+	// Collection is the name of the collection
+	Collection string
+	// Ref is the reference of the document, only set for operations on a single document
+	Ref Reference
+	// Duration is the time the operation took
+	Duration time.Duration
+	// DocCount is the number of documents that were added, deleted or found
+	DocCount int
+}
+
+// hooks holds the registered hooks per EventType
+type hooks struct {
+	mutex sync.RWMutex
+	hooks map[EventType][]func(EventData)
+}
+
+func (h *hooks) add(event EventType, fn func(EventData)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.hooks == nil {
+		h.hooks = map[EventType][]func(EventData){}
+	}
+	h.hooks[event] = append(h.hooks[event], fn)
+}
+
+func (h *hooks) remove(event EventType) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.hooks, event)
+}
+
+// emit calls all hooks for the given event synchronously
+func (h *hooks) emit(event EventType, data EventData) {
+	h.mutex.RLock()
+	fns := h.hooks[event]
+	h.mutex.RUnlock()
+
+	for _, fn := range fns {
+		fn(data)
+	}
+}
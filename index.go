@@ -21,10 +21,55 @@ package leia
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
 	"go.etcd.io/bbolt"
 )
 
+// ErrIndexSchemaMismatch is returned when an index is added while an index with the same name but a different configuration is stored.
+// Drop the index and add it again to rebuild it.
+var ErrIndexSchemaMismatch = errors.New("index configuration does not match stored index")
+
+// ErrIndexConflict is returned when an index is added while an index with the same name but a different configuration has been added to the collection.
+// It wraps ErrIndexSchemaMismatch, which was returned for this case before.
+var ErrIndexConflict = fmt.Errorf("%w: an index with the same name has been added", ErrIndexSchemaMismatch)
+
+// ErrInvalidIndexName is returned when an index is added or renamed with a name that can't be used as bucket name
+var ErrInvalidIndexName = errors.New("invalid index name")
+
+// maxIndexNameLength is the maximum length of an index name in bytes
+const maxIndexNameLength = 255
+
+// indexMetadataKeyPrefix is the prefix of the collection metadata key that holds the indexMetadata, followed by the bucket name of the index.
+// It's not stored in the index bucket, since any key in there can be an indexed value.
+const indexMetadataKeyPrefix = reservedMetadataPrefix + "index."
+
+// indexMetadataVersion is the current version of the indexMetadata format
+const indexMetadataVersion = 1
+
+// validateIndexName checks if the name can be used for the bucket of an index.
+// Names starting with an underscore are reserved for internal buckets, like the documents bucket.
+func validateIndexName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("%w: name is empty", ErrInvalidIndexName)
+	}
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("%w: names starting with '_' are reserved, use %q instead", ErrInvalidIndexName, strings.TrimLeft(name, "_"))
+	}
+	if len(name) > maxIndexNameLength {
+		return fmt.Errorf("%w: name exceeds %d bytes, use a shorter name like %q", ErrInvalidIndexName, maxIndexNameLength, name[:maxIndexNameLength])
+	}
+	return nil
+}
+
 // Index describes an index. An index is based on a json path and has a path.
 // The path is used for storage but also as identifier in search options.
 type Index interface {
@@ -44,11 +89,51 @@ type Index interface {
 	// BucketName returns the bucket path for this index
 	BucketName() []byte
 	// QueryPartsOutsideIndex selects the queryParts that are not covered by the index.
-	QueryPartsOutsideIndex(query Query) []QueryPart
-	// Depth returns the number of indexed fields
+	// It returns ErrInvalidQuery if a query part has no path, since it can't be resolved against the index nor the documents.
+	QueryPartsOutsideIndex(query Query) ([]QueryPart, error)
+	// Depth returns the number of indexed fields, it's not related to IndexStats.BucketDepth
 	Depth() int
+	// Fields returns the FieldIndexers of the index, in the order they're used to compose the index keys
+	Fields() []FieldIndexer
 	// Keys returns the scalars found in the document at the location specified by the FieldIndexer
 	Keys(fi FieldIndexer, document Document) ([]Scalar, error)
+	// Meta returns the metadata that's stored with the index when it's built.
+	// It returns ErrIndexNotFound if the index hasn't been added to the collection.
+	Meta() (IndexMeta, error)
+	// Stats returns the storage statistics of the index, read from the bbolt bucket of the index.
+	// It returns ErrIndexNotFound if the index hasn't been added to the collection.
+	Stats() (IndexStats, error)
+	// SameAs returns true if the other index has the same FieldIndexers, comparing the paths, tokenizers and transformers.
+	// The names of the indices aren't compared.
+	SameAs(other Index) bool
+}
+
+// IndexStats contains the storage statistics of an index.
+type IndexStats struct {
+	// KeyCount is the number of unique keys in the index. For a compound index, a key is the combination of all field values.
+	KeyCount int
+	// BucketDepth is the depth of the bbolt B+tree of the index bucket, including the nested buckets of the keys.
+	// It's unrelated to Index.Depth, which is the number of indexed fields.
+	BucketDepth int
+	// EstimatedBytes is the number of bytes allocated for the index in the bbolt file
+	EstimatedBytes int64
+}
+
+// IndexMeta contains the metadata of a stored index.
+// The fields are zero for indices that were built before the metadata was stored.
+type IndexMeta struct {
+	// CreatedAt is the time the index was built
+	CreatedAt time.Time
+	// FieldCount is the number of indexed fields
+	FieldCount int
+}
+
+// indexMetadata is stored within an index bucket to detect changes in the index configuration
+type indexMetadata struct {
+	Version    int    `json:"version"`
+	Hash       string `json:"hash"`
+	CreatedAt  string `json:"created_at,omitempty"`
+	FieldCount int    `json:"field_count"`
 }
 
 // iteratorFn defines a function that is used as a callback when an IterateIndex query finds results. The function is called for each result entry.
@@ -69,12 +154,193 @@ func (i *index) BucketName() []byte {
 	return []byte(i.Name())
 }
 
+func (i *index) Fields() []FieldIndexer {
+	fields := make([]FieldIndexer, len(i.indexParts))
+	copy(fields, i.indexParts)
+	return fields
+}
+
 func (i *index) Depth() int {
 	return len(i.indexParts)
 }
 
+// metadata returns the indexMetadata for the current configuration of the index
+func (i *index) metadata() indexMetadata {
+	h := sha1.New()
+	for _, part := range i.indexParts {
+		_, _ = fmt.Fprintln(h, describeFieldIndexer(part))
+	}
+	return indexMetadata{
+		Version:    indexMetadataVersion,
+		Hash:       hex.EncodeToString(h.Sum(nil)),
+		FieldCount: len(i.indexParts),
+	}
+}
+
+func (i *index) Meta() (IndexMeta, error) {
+	c, ok := i.collection.(*collection)
+	if !ok {
+		return IndexMeta{}, fmt.Errorf("%w: %s", ErrIndexNotFound, i.Name())
+	}
+	var data []byte
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		if bucket.Bucket(i.BucketName()) == nil {
+			return nil
+		}
+		if metaBucket := bucket.Bucket([]byte(collectionMetadataBucket)); metaBucket != nil {
+			// copy the data, it's only valid during the transaction
+			data = append([]byte{}, metaBucket.Get(indexMetadataKey(i.BucketName()))...)
+		}
+		return nil
+	})
+	if err != nil {
+		return IndexMeta{}, err
+	}
+	if data == nil {
+		return IndexMeta{}, fmt.Errorf("%w: %s", ErrIndexNotFound, i.Name())
+	}
+
+	var stored indexMetadata
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return IndexMeta{}, fmt.Errorf("invalid metadata for index %s: %w", i.Name(), err)
+	}
+	meta := IndexMeta{FieldCount: stored.FieldCount}
+	if stored.CreatedAt != "" {
+		if meta.CreatedAt, err = time.Parse(time.RFC3339, stored.CreatedAt); err != nil {
+			return IndexMeta{}, fmt.Errorf("invalid metadata for index %s: %w", i.Name(), err)
+		}
+	}
+	return meta, nil
+}
+
+func (i *index) Stats() (IndexStats, error) {
+	c, ok := i.collection.(*collection)
+	if !ok {
+		return IndexStats{}, fmt.Errorf("%w: %s", ErrIndexNotFound, i.Name())
+	}
+	var stats *IndexStats
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.name))
+		if bucket == nil {
+			return nil
+		}
+		iBucket := bucket.Bucket(i.BucketName())
+		if iBucket == nil {
+			return nil
+		}
+		bucketStats := iBucket.Stats()
+		stats = &IndexStats{
+			BucketDepth:    bucketStats.Depth,
+			EstimatedBytes: int64(bucketStats.BranchAlloc + bucketStats.LeafAlloc + bucketStats.InlineBucketInuse),
+		}
+		// every key is a nested bucket holding the references
+		return iBucket.ForEach(func(_, v []byte) error {
+			if v == nil {
+				stats.KeyCount++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return IndexStats{}, err
+	}
+	if stats == nil {
+		return IndexStats{}, fmt.Errorf("%w: %s", ErrIndexNotFound, i.Name())
+	}
+	return *stats, nil
+}
+
+func (i *index) SameAs(other Index) bool {
+	return sameFields(i, other)
+}
+
+// sameFields returns true if both indices have the same FieldIndexers
+func sameFields(a Index, b Index) bool {
+	aFields := a.Fields()
+	bFields := b.Fields()
+	if len(aFields) != len(bFields) {
+		return false
+	}
+	for j := range aFields {
+		if !aFields[j].EqualsIndexer(bFields[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexMetadataKey returns the key in the collection metadata bucket that holds the indexMetadata of the index bucket
+func indexMetadataKey(bucketName []byte) []byte {
+	return append([]byte(indexMetadataKeyPrefix), bucketName...)
+}
+
+// getIndexMetadata returns the stored indexMetadata of the index bucket, or nil if it isn't stored.
+// The bucket is the collection bucket.
+func getIndexMetadata(bucket *bbolt.Bucket, bucketName []byte) []byte {
+	metaBucket := bucket.Bucket([]byte(collectionMetadataBucket))
+	if metaBucket == nil {
+		return nil
+	}
+	return metaBucket.Get(indexMetadataKey(bucketName))
+}
+
+// putIndexMetadata stores the indexMetadata of the index in the metadata of the collection bucket, createdAt is omitted when it's zero.
+// Only indices created by Collection.NewIndex have metadata.
+func putIndexMetadata(bucket *bbolt.Bucket, idx Index, createdAt time.Time) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return nil
+	}
+	metadata := i.metadata()
+	if !createdAt.IsZero() {
+		metadata.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(metadata)
+	metaBucket, err := bucket.CreateBucketIfNotExists([]byte(collectionMetadataBucket))
+	if err != nil {
+		return err
+	}
+	return metaBucket.Put(indexMetadataKey(i.BucketName()), data)
+}
+
+// deleteIndexMetadata removes the indexMetadata of the index bucket from the collection bucket
+func deleteIndexMetadata(bucket *bbolt.Bucket, bucketName []byte) error {
+	metaBucket := bucket.Bucket([]byte(collectionMetadataBucket))
+	if metaBucket == nil {
+		return nil
+	}
+	return metaBucket.Delete(indexMetadataKey(bucketName))
+}
+
+// indexMetadataMatches returns false if the collection bucket holds metadata for a different configuration of the index.
+// Metadata is added for indices that were created before metadata was stored.
+func indexMetadataMatches(bucket *bbolt.Bucket, idx Index) (bool, error) {
+	i, ok := idx.(*index)
+	if !ok {
+		return true, nil
+	}
+	data := getIndexMetadata(bucket, i.BucketName())
+	if data == nil {
+		// the time the index was built is unknown
+		return true, putIndexMetadata(bucket, idx, time.Time{})
+	}
+	var stored indexMetadata
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return false, fmt.Errorf("invalid metadata for index %s: %w", i.Name(), err)
+	}
+	current := i.metadata()
+	return stored.Version == current.Version && stored.Hash == current.Hash, nil
+}
+
 func (i *index) Add(bucket *bbolt.Bucket, ref Reference, doc Document) error {
-	cBucket, _ := bucket.CreateBucketIfNotExists(i.BucketName())
+	cBucket, err := bucket.CreateBucketIfNotExists(i.BucketName())
+	if err != nil {
+		return err
+	}
 	return i.addDocumentR(cBucket, i.indexParts, Key{}, ref, doc)
 }
 
@@ -93,11 +359,13 @@ func (i *index) addDocumentR(bucket *bbolt.Bucket, parts []FieldIndexer, cKey Ke
 		// all matches to be added to current bucket
 		for _, m := range matches {
 			key := ComposeKey(cKey, m.Bytes())
-			_ = addRefToBucket(bucket, key, ref)
+			if err = addRefToBucket(bucket, key, ref); err != nil {
+				return err
+			}
 		}
 		if len(matches) == 0 {
 			key := ComposeKey(cKey, []byte{})
-			_ = addRefToBucket(bucket, key, ref)
+			return addRefToBucket(bucket, key, ref)
 		}
 		return nil
 	}
@@ -160,6 +428,10 @@ func (i *index) Delete(bucket *bbolt.Bucket, ref Reference, doc Document) error
 
 // addRefToBucket adds the reference to the correct key in the bucket. It handles multiple reference on the same location
 func addRefToBucket(bucket *bbolt.Bucket, key Key, ref Reference) error {
+	// bbolt doesn't support empty keys, an empty value can't be indexed
+	if len(key) == 0 {
+		return nil
+	}
 	// first check if there's a sub-bucket
 	subBucket, err := bucket.CreateBucketIfNotExists(key)
 	if err != nil {
@@ -205,6 +477,10 @@ func (i *index) matchingParts(query Query) []QueryPart {
 	var sorted = make([]QueryPart, len(i.indexParts))
 outer:
 	for _, qp := range query.parts {
+		// a suffix can't be found using the sorted index keys
+		if !isIndexable(qp) {
+			continue
+		}
 		for j, ip := range i.indexParts {
 			if ip.Equals(qp) {
 				if sorted[j] == nil {
@@ -225,7 +501,12 @@ outer:
 	return sorted
 }
 
-func (i *index) QueryPartsOutsideIndex(query Query) []QueryPart {
+func (i *index) QueryPartsOutsideIndex(query Query) ([]QueryPart, error) {
+	for _, qp := range query.parts {
+		if qp.QueryPath() == nil {
+			return nil, fmt.Errorf("%w: query part without path", ErrInvalidQuery)
+		}
+	}
 	matchingParts := i.matchingParts(query)
 	resultingParts := make([]QueryPart, 0)
 	visitedParts := make([]QueryPart, 0)
@@ -249,7 +530,7 @@ outer:
 		resultingParts = append(resultingParts, qp)
 	}
 
-	return resultingParts
+	return resultingParts, nil
 }
 
 func (i *index) Iterate(bucket *bbolt.Bucket, query Query, fn iteratorFn) error {
@@ -270,22 +551,67 @@ func (i *index) Iterate(bucket *bbolt.Bucket, query Query, fn iteratorFn) error
 	// extract tokenizer and transform to here
 	matchers := i.matchers(sortedQueryParts)
 
-	_, err = findR(cBucket.Cursor(), Key{}, matchers, fn, []byte{}, 0)
+	for j, m := range matchers {
+		i.log().Debug("index seek", "index", i.Name(), "field", i.indexParts[j].QueryPath(), "terms", len(m.terms), "keys", describeTerms(m.terms))
+	}
+	_, err = findR(cBucket.Cursor(), Key{}, matchers, fn, []byte{}, 0, len(i.indexParts))
 	return err
 }
 
+// log returns the logger of the collection of the index
+func (i *index) log() *slog.Logger {
+	if c, ok := i.collection.(*collection); ok {
+		return c.log()
+	}
+	return discardLogger
+}
+
+// describeTerms returns the formatted keys of the seek terms of a matcher
+func describeTerms(terms []Scalar) string {
+	formatted := make([]string, len(terms))
+	for j, term := range terms {
+		formatted[j] = FormatKey(term.Bytes(), 1)
+	}
+	return strings.Join(formatted, ", ")
+}
+
 func (i *index) matchers(sortedQueryParts []QueryPart) []matcher {
 	// extract tokenizer and transform to here
 	matchers := make([]matcher, len(sortedQueryParts))
 	for j, cPart := range sortedQueryParts {
-		terms := make([]Scalar, 0)
-		for _, token := range i.indexParts[j].Tokenize(cPart.Seek()) {
-			seek := i.indexParts[j].Transform(token)
-			terms = append(terms, seek)
+		seeks := seekTerms(cPart)
+		candidates := make([]seekTerm, 0, len(seeks))
+		for _, seek := range seeks {
+			for _, token := range i.indexParts[j].Tokenize(seek.value) {
+				term := i.indexParts[j].Transform(token)
+				// a filtered term isn't indexed, so it can't be found
+				if !isFiltered(token, term) {
+					candidates = append(candidates, seekTerm{value: term, exclusive: seek.exclusive})
+				}
+			}
+		}
+		if len(seeks) > 1 {
+			// the cursor can only move forward, so the branches of an Or or the values of an In are visited in order
+			sort.SliceStable(candidates, func(a, b int) bool {
+				return bytes.Compare(candidates[a].value.Bytes(), candidates[b].value.Bytes()) < 0
+			})
+		}
+		terms := make([]Scalar, 0, len(candidates))
+		exclusive := make([]bool, 0, len(candidates))
+		for _, candidate := range candidates {
+			last := len(terms) - 1
+			if last >= 0 && bytes.Equal(terms[last].Bytes(), candidate.value.Bytes()) {
+				// the same term is sought once, it's only exclusive if all seeks exclude it
+				exclusive[last] = exclusive[last] && candidate.exclusive
+				continue
+			}
+			terms = append(terms, candidate.value)
+			exclusive = append(exclusive, candidate.exclusive)
 		}
 		matchers[j] = matcher{
 			queryPart: cPart,
 			terms:     terms,
+			exclusive: exclusive,
 			transform: i.indexParts[j].Transform,
 		}
 	}
@@ -296,6 +622,10 @@ func (i *index) Keys(j FieldIndexer, document Document) ([]Scalar, error) {
 	// first get the raw values from the query path
 	rawKeys, err := i.collection.ValuesAtPath(document, j.QueryPath())
 	if err != nil {
+		if skipOnError(j) {
+			i.log().Warn("values can't be collected, field isn't indexed for the document", "index", i.Name(), "field", j.QueryPath(), "error", err)
+			return []Scalar{}, nil
+		}
 		return nil, err
 	}
 
@@ -307,74 +637,138 @@ func (i *index) Keys(j FieldIndexer, document Document) ([]Scalar, error) {
 	}
 
 	// run the transformer
-	transformed := make([]Scalar, len(tokenized))
-	for i, rawKey := range tokenized {
-		transformed[i] = j.Transform(rawKey)
+	transformed := make([]Scalar, 0, len(tokenized))
+	for _, rawKey := range tokenized {
+		key := j.Transform(rawKey)
+		if isFiltered(rawKey, key) {
+			continue
+		}
+		transformed = append(transformed, key)
 	}
 
 	return transformed, nil
 }
 
+// isFiltered returns true if a Transform like StopWordFilter turned the value into an empty value, which isn't indexed
+func isFiltered(value Scalar, transformed Scalar) bool {
+	return len(transformed.Bytes()) == 0 && len(value.Bytes()) > 0
+}
+
 type matcher struct {
 	queryPart QueryPart
 	terms     []Scalar
+	// exclusive is true for terms that don't match the keys equal to them, like the value of a GreaterThan
+	exclusive []bool
 	transform Transform
 }
 
-func findR(cursor *bbolt.Cursor, searchKey Key, matchers []matcher, fn iteratorFn, lastCursorPosition []byte, depth int) ([]byte, error) {
-	var err error
-	returnKey := lastCursorPosition
-	currentQueryPart := matchers[0].queryPart
-	//outer:
-	for _, seekTerm := range matchers[0].terms {
-		// new location in cursor to skip to
-		seek := ComposeKey(searchKey, seekTerm.Bytes())
-		condition := true
-
-		// do not go back to prevent infinite loops. The cursor may only go forward.
-		if bytes.Compare(seek, lastCursorPosition) < 0 {
-			seek = lastCursorPosition
+// findFrame holds the state of findR for a single index part
+type findFrame struct {
+	// searchKey is the key prefix composed of the matching values of the previous index parts
+	searchKey Key
+	// matchers starts with the matcher of this index part
+	matchers []matcher
+	depth    int
+	// lastCursorPosition is the position of the cursor when the frame was started, the cursor may not go back
+	lastCursorPosition []byte
+	returnKey          []byte
+	// term is the index of the seek term that is being scanned, scanning is false between terms
+	term       int
+	scanning   bool
+	currentKey []byte
+	condition  bool
+}
+
+// findR walks the cursor over the index keys matching the matchers and calls fn for every reference.
+// Instead of recursing for every index part, a frame per index part is kept on a stack.
+// fieldCount is the number of fields of the index, every key consists of that many parts.
+// It returns the last position of the cursor.
+func findR(cursor *bbolt.Cursor, searchKey Key, matchers []matcher, fn iteratorFn, lastCursorPosition []byte, depth int, fieldCount int) ([]byte, error) {
+	stack := []*findFrame{{searchKey: searchKey, matchers: matchers, depth: depth, lastCursorPosition: lastCursorPosition, returnKey: lastCursorPosition}}
+	// subKey is the position returned by the frame that was popped last
+	var subKey []byte
+	nested := false
+
+	for len(stack) > 0 {
+		frame := stack[len(stack)-1]
+		current := frame.matchers[0]
+
+		if nested {
+			nested = false
+			if bytes.Equal(subKey, frame.currentKey) {
+				// the nested search could not advance the cursor, so we do it here before continuing the loop
+				frame.currentKey, _ = cursor.Next()
+				frame.returnKey = frame.currentKey
+			} else {
+				// on success the cursor is moved forward, continue with the latest key
+				frame.currentKey = subKey
+			}
+		} else if !frame.scanning {
+			if frame.term == len(current.terms) {
+				stack = stack[:len(stack)-1]
+				subKey = frame.returnKey
+				nested = true
+				continue
+			}
+			// new location in cursor to skip to
+			seek := ComposeKey(frame.searchKey, current.terms[frame.term].Bytes())
+			// do not go back to prevent infinite loops. The cursor may only go forward.
+			if bytes.Compare(seek, frame.lastCursorPosition) < 0 {
+				seek = frame.lastCursorPosition
+			}
+			frame.currentKey, _ = cursor.Seek(seek)
+			frame.condition = true
+			frame.scanning = true
 		}
 
-		var currentKey []byte
-		for currentKey, _ = cursor.Seek(seek); currentKey != nil && bytes.HasPrefix(currentKey, searchKey) && condition; {
+		descended := false
+		for frame.currentKey != nil && bytes.HasPrefix(frame.currentKey, frame.searchKey) && frame.condition {
 			var newPart []byte
-			split := Key(currentKey).Split()
-			if len(split) > depth {
-				newPart = split[depth]
+			// a delimiter within a value can only be kept in the last part
+			split := Key(frame.currentKey).SplitN(fieldCount)
+			if len(split) > frame.depth {
+				newPart = split[frame.depth]
 			} // else use nil value, should not happen, but better to prevent panics
 
+			// keys equal to the seek term don't match an exclusive query part, but the keys after them might
+			if current.exclusive[frame.term] && bytes.Equal(newPart, current.terms[frame.term].Bytes()) {
+				frame.currentKey, _ = cursor.Next()
+				continue
+			}
+
 			// check of current (partial) key still matches with query
-			condition = currentQueryPart.Condition(newPart, matchers[0].transform)
-			if condition {
-				if len(matchers) > 1 {
-					// (partial) key still matches, continue to next index part
-					nKey := ComposeKey(searchKey, newPart)
-					// on success the cursor is moved forward, the latest key is returned, continue with that key
-					// if keys haven't changed: break
-					var subKey []byte
-					subKey, err = findR(cursor, nKey, matchers[1:], fn, currentKey, depth+1)
-					if bytes.Equal(subKey, currentKey) {
-						// the nested search could not advance the cursor, so we do it here before continuing the loop
-						currentKey, _ = cursor.Next()
-						returnKey = currentKey
-						continue
-					}
-					currentKey = subKey
-				} else {
-					// all index parts applied to key construction, retrieve results.
-					err = iterateOverDocuments(cursor, currentKey, fn)
-					// this position was a success, hopefully the next as well
-					currentKey, _ = cursor.Next()
-				}
-				if err != nil {
-					return nil, err
-				}
+			frame.condition = current.queryPart.Condition(newPart, current.transform)
+			if !frame.condition {
+				break
+			}
+			if len(frame.matchers) > 1 {
+				// (partial) key still matches, continue to next index part
+				stack = append(stack, &findFrame{
+					searchKey:          ComposeKey(frame.searchKey, newPart),
+					matchers:           frame.matchers[1:],
+					depth:              frame.depth + 1,
+					lastCursorPosition: frame.currentKey,
+					returnKey:          frame.currentKey,
+				})
+				descended = true
+				break
 			}
+			// all index parts applied to key construction, retrieve results.
+			if err := iterateOverDocuments(cursor, frame.currentKey, fn); err != nil {
+				return nil, err
+			}
+			// this position was a success, hopefully the next as well
+			frame.currentKey, _ = cursor.Next()
+		}
+		if descended {
+			continue
 		}
-		returnKey = currentKey
+
+		frame.returnKey = frame.currentKey
+		frame.term++
+		frame.scanning = false
 	}
-	return returnKey, nil
+	return subKey, nil
 }
 
 func iterateOverDocuments(cursor *bbolt.Cursor, cKey []byte, fn iteratorFn) error {
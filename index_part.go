@@ -19,6 +19,13 @@
 
 package leia
 
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+)
+
 // IndexOption is the option function for adding options to a FieldIndexer
 type IndexOption func(fieldIndexer *fieldIndexer)
 
@@ -38,7 +45,18 @@ func TokenizerOption(tokenizer Tokenizer) IndexOption {
 	}
 }
 
+// SkipOnErrorOption is the option for a FieldIndexer to ignore errors while collecting values from a document.
+// A document for which the values can't be collected is indexed as if the field is missing, the error is logged at warn level.
+// By default, the error is returned and the document is not added.
+func SkipOnErrorOption() IndexOption {
+	return func(fieldIndexer *fieldIndexer) {
+		fieldIndexer.skipOnError = true
+	}
+}
+
 // QueryPathComparable defines if two structs can be compared on query path.
+// It's implemented by both FieldIndexer and QueryPart and is used to select an index for a query.
+// Custom QueryParts must implement it to be matched against the FieldIndexers of an index.
 type QueryPathComparable interface {
 	// Equals returns true if the two QueryPathComparable have the same search path.
 	Equals(other QueryPathComparable) bool
@@ -48,6 +66,7 @@ type QueryPathComparable interface {
 
 // FieldIndexer is the public interface that defines functions for a field index instruction.
 // A FieldIndexer is used when a document is indexed.
+// The QueryPath of the indexed field is exposed through the embedded QueryPathComparable.
 type FieldIndexer interface {
 	QueryPathComparable
 	// Tokenize may split up Keys and search terms. For example split a sentence into words.
@@ -55,12 +74,16 @@ type FieldIndexer interface {
 	// Transform is a function that alters the value to be indexed as well as any search criteria.
 	// For example LowerCase is a Transform function that transforms the value to lower case.
 	Transform(value Scalar) Scalar
+	// EqualsIndexer returns true if the other FieldIndexer indexes the same path with the same transformer and tokenizer.
+	// Equals only compares the path, since it's used to match query parts.
+	EqualsIndexer(other FieldIndexer) bool
 }
 
-// NewFieldIndexer creates a new fieldIndexer
-func NewFieldIndexer(jsonPath QueryPath, options ...IndexOption) FieldIndexer {
+// NewFieldIndexer creates a new fieldIndexer for the given QueryPath,
+// a JSONPath for a JSON collection or an IRIPath for a JSON-LD collection.
+func NewFieldIndexer(queryPath QueryPath, options ...IndexOption) FieldIndexer {
 	fi := fieldIndexer{
-		queryPath: jsonPath,
+		queryPath: queryPath,
 	}
 	for _, o := range options {
 		o(&fi)
@@ -72,12 +95,22 @@ type fieldIndexer struct {
 	queryPath   QueryPath
 	transformer Transform
 	tokenizer   Tokenizer
+	skipOnError bool
 }
 
 func (j fieldIndexer) Equals(other QueryPathComparable) bool {
 	return j.queryPath.Equals(other.QueryPath())
 }
 
+func (j fieldIndexer) EqualsIndexer(other FieldIndexer) bool {
+	o, ok := other.(fieldIndexer)
+	return ok &&
+		j.queryPath.Equals(o.queryPath) &&
+		sameFunc(j.transformer, o.transformer) &&
+		sameFunc(j.tokenizer, o.tokenizer) &&
+		j.skipOnError == o.skipOnError
+}
+
 func (j fieldIndexer) QueryPath() QueryPath {
 	return j.queryPath
 }
@@ -104,3 +137,49 @@ func (j fieldIndexer) Transform(value Scalar) Scalar {
 	}
 	return j.transformer(value)
 }
+
+// skipOnError returns true if the FieldIndexer has been configured with the SkipOnErrorOption
+func skipOnError(fi FieldIndexer) bool {
+	j, ok := fi.(fieldIndexer)
+	return ok && j.skipOnError
+}
+
+// describeFieldIndexer returns a description of the FieldIndexer configuration, used to detect changes to a stored index.
+// Transformers and tokenizers are described by their function name. Anonymous functions and closures are only described as being set,
+// since their generated names change with unrelated code changes. A change in their parameters, like NGramTokenizer(4) instead of NGramTokenizer(3), isn't detected.
+func describeFieldIndexer(fi FieldIndexer) string {
+	j, ok := fi.(fieldIndexer)
+	if !ok {
+		return fmt.Sprintf("%T %T %#v", fi, fi.QueryPath(), fi.QueryPath())
+	}
+	return fmt.Sprintf("%T %#v %s %s %t", j.queryPath, j.queryPath, stableFuncName(j.transformer), stableFuncName(j.tokenizer), j.skipOnError)
+}
+
+// sameFunc returns true if both functions are nil or point to the same code.
+// Closures created by the same function, like NGramTokenizer(3) and NGramTokenizer(4), can't be distinguished.
+func sameFunc(a interface{}, b interface{}) bool {
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+	aNil := !va.IsValid() || va.IsNil()
+	bNil := !vb.IsValid() || vb.IsNil()
+	if aNil || bNil {
+		return aNil && bNil
+	}
+	return va.Pointer() == vb.Pointer()
+}
+
+// anonymousFuncName matches the generated part of the name of an anonymous function, like "main.main.func1"
+var anonymousFuncName = regexp.MustCompile(`\.func\d+`)
+
+// stableFuncName returns the name of a named function, "func" for an anonymous function and an empty string for nil
+func stableFuncName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.IsNil() {
+		return ""
+	}
+	name := runtime.FuncForPC(v.Pointer()).Name()
+	if anonymousFuncName.MatchString(name) {
+		return "func"
+	}
+	return name
+}
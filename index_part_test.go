@@ -37,4 +37,73 @@ func TestNewIndexPart(t *testing.T) {
 		}
 		assert.Equal(t, path, jip.QueryPath())
 	})
+
+	t.Run("ok - QueryPath on interface", func(t *testing.T) {
+		path := NewIRIPath("http://example.com/name")
+		var ip FieldIndexer = NewFieldIndexer(path)
+
+		assert.True(t, path.Equals(ip.QueryPath()))
+	})
+}
+
+func TestDescribeFieldIndexer(t *testing.T) {
+	path := NewJSONPath("path")
+
+	t.Run("ok - same configuration", func(t *testing.T) {
+		assert.Equal(t, describeFieldIndexer(NewFieldIndexer(path, TransformerOption(ToLower))), describeFieldIndexer(NewFieldIndexer(path, TransformerOption(ToLower))))
+	})
+
+	t.Run("ok - different transformer", func(t *testing.T) {
+		assert.NotEqual(t, describeFieldIndexer(NewFieldIndexer(path)), describeFieldIndexer(NewFieldIndexer(path, TransformerOption(ToLower))))
+	})
+
+	t.Run("ok - different tokenizer", func(t *testing.T) {
+		assert.NotEqual(t, describeFieldIndexer(NewFieldIndexer(path)), describeFieldIndexer(NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer))))
+	})
+
+	t.Run("ok - different path type", func(t *testing.T) {
+		assert.NotEqual(t, describeFieldIndexer(NewFieldIndexer(NewIRIPath("path"))), describeFieldIndexer(NewFieldIndexer(path)))
+	})
+
+	t.Run("ok - different skipOnError", func(t *testing.T) {
+		assert.NotEqual(t, describeFieldIndexer(NewFieldIndexer(path)), describeFieldIndexer(NewFieldIndexer(path, SkipOnErrorOption())))
+	})
+
+	t.Run("ok - anonymous functions are described without their generated name", func(t *testing.T) {
+		first := func(scalar Scalar) Scalar { return scalar }
+		second := func(scalar Scalar) Scalar { return scalar }
+
+		assert.Equal(t, describeFieldIndexer(NewFieldIndexer(path, TransformerOption(first))), describeFieldIndexer(NewFieldIndexer(path, TransformerOption(second))))
+		assert.Equal(t, describeFieldIndexer(NewFieldIndexer(path, TokenizerOption(NGramTokenizer(3)))), describeFieldIndexer(NewFieldIndexer(path, TokenizerOption(NGramTokenizer(4)))))
+		assert.NotEqual(t, describeFieldIndexer(NewFieldIndexer(path)), describeFieldIndexer(NewFieldIndexer(path, TransformerOption(first))))
+	})
+}
+
+func TestFieldIndexer_EqualsIndexer(t *testing.T) {
+	path := NewJSONPath("path")
+
+	t.Run("true - same configuration", func(t *testing.T) {
+		assert.True(t, NewFieldIndexer(path, TransformerOption(ToLower)).EqualsIndexer(NewFieldIndexer(path, TransformerOption(ToLower))))
+	})
+
+	t.Run("true - without options", func(t *testing.T) {
+		assert.True(t, NewFieldIndexer(path).EqualsIndexer(NewFieldIndexer(NewJSONPath("path"))))
+	})
+
+	t.Run("false - different path", func(t *testing.T) {
+		assert.False(t, NewFieldIndexer(path).EqualsIndexer(NewFieldIndexer(NewJSONPath("other"))))
+	})
+
+	t.Run("false - different transformer", func(t *testing.T) {
+		assert.False(t, NewFieldIndexer(path, TransformerOption(ToUpper)).EqualsIndexer(NewFieldIndexer(path, TransformerOption(ToLower))))
+		assert.False(t, NewFieldIndexer(path).EqualsIndexer(NewFieldIndexer(path, TransformerOption(ToLower))))
+	})
+
+	t.Run("false - different tokenizer", func(t *testing.T) {
+		assert.False(t, NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer)).EqualsIndexer(NewFieldIndexer(path, TokenizerOption(OrderedWhiteSpaceTokenizer))))
+	})
+
+	t.Run("false - other FieldIndexer type", func(t *testing.T) {
+		assert.False(t, NewFieldIndexer(path).EqualsIndexer(testIndexPart{path: "path"}))
+	})
 }
@@ -20,9 +20,15 @@
 package leia
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
+	"log/slog"
 	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.etcd.io/bbolt"
@@ -39,6 +45,150 @@ func TestNewIndex(t *testing.T) {
 	assert.Len(t, i.(*index).indexParts, 0)
 }
 
+func TestIndex_Fields(t *testing.T) {
+	_, c := testCollection(t)
+	key := NewJSONPath("path.part")
+	i := c.NewIndex("path", NewFieldIndexer(key, TransformerOption(ToLower)), NewFieldIndexer(NewJSONPath("other")))
+
+	fields := i.Fields()
+
+	if !assert.Len(t, fields, 2) {
+		return
+	}
+	assert.Equal(t, key, fields[0].QueryPath())
+	assert.Equal(t, MustParseScalar("a"), fields[0].Transform(MustParseScalar("A")))
+	// modifying the result doesn't change the index
+	fields[0] = nil
+	assert.NotNil(t, i.Fields()[0])
+}
+
+func TestIndex_Meta(t *testing.T) {
+	t.Run("ok - stored when the index is added", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex("path", NewFieldIndexer(NewJSONPath("path.part")), NewFieldIndexer(NewJSONPath("other")))
+		before := time.Now().Truncate(time.Second)
+		_ = c.AddIndex(i)
+
+		meta, err := i.Meta()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, meta.FieldCount)
+		assert.False(t, meta.CreatedAt.Before(before))
+		assert.False(t, meta.CreatedAt.After(time.Now()))
+	})
+
+	t.Run("ok - index built before metadata was stored", func(t *testing.T) {
+		db, c, i := testIndex(t)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			bucket, _ := tx.CreateBucketIfNotExists([]byte(c.name))
+			_, err := bucket.CreateBucket(i.BucketName())
+			return err
+		})
+		_ = c.AddIndex(i)
+
+		meta, err := i.Meta()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, meta.CreatedAt.IsZero())
+	})
+
+	t.Run("error - index not added", func(t *testing.T) {
+		_, _, i := testIndex(t)
+
+		_, err := i.Meta()
+
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+	})
+}
+
+func TestIndex_SameAs(t *testing.T) {
+	_, c := testCollection(t)
+	path := NewJSONPath("path.part")
+	i := c.NewIndex("index", NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ToLower)))
+
+	t.Run("ok - same fields with another name", func(t *testing.T) {
+		assert.True(t, i.SameAs(c.NewIndex("other", NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ToLower)))))
+	})
+
+	t.Run("ok - other transformer", func(t *testing.T) {
+		assert.False(t, i.SameAs(c.NewIndex("index", NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ToUpper)))))
+	})
+
+	t.Run("ok - other tokenizer", func(t *testing.T) {
+		assert.False(t, i.SameAs(c.NewIndex("index", NewFieldIndexer(path, TokenizerOption(OrderedWhiteSpaceTokenizer), TransformerOption(ToLower)))))
+	})
+
+	t.Run("ok - other number of fields", func(t *testing.T) {
+		assert.False(t, i.SameAs(c.NewIndex("index", NewFieldIndexer(path, TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ToLower)), NewFieldIndexer(NewJSONPath("other")))))
+	})
+}
+
+func TestIndex_Stats(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex("text", NewFieldIndexer(NewJSONPath("text"), TokenizerOption(WhiteSpaceTokenizer)))
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{
+			[]byte(`{"text": "the quick brown fox"}`),
+			[]byte(`{"text": "the lazy dog"}`),
+		})
+
+		stats, err := i.Stats()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 6, stats.KeyCount)
+		assert.GreaterOrEqual(t, stats.BucketDepth, 1)
+		assert.Greater(t, stats.EstimatedBytes, int64(0))
+	})
+
+	t.Run("ok - empty index", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		_ = c.AddIndex(i)
+
+		stats, err := i.Stats()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 0, stats.KeyCount)
+	})
+
+	t.Run("error - index not added", func(t *testing.T) {
+		_, _, i := testIndex(t)
+
+		_, err := i.Stats()
+
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+	})
+}
+
+func TestValidateIndexName(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		assert.NoError(t, validateIndexName("index"))
+	})
+
+	t.Run("error - empty", func(t *testing.T) {
+		assert.ErrorIs(t, validateIndexName(""), ErrInvalidIndexName)
+	})
+
+	t.Run("error - reserved", func(t *testing.T) {
+		err := validateIndexName("_index")
+
+		assert.ErrorIs(t, err, ErrInvalidIndexName)
+		assert.Contains(t, err.Error(), `use "index" instead`)
+	})
+
+	t.Run("error - too long", func(t *testing.T) {
+		assert.ErrorIs(t, validateIndexName(strings.Repeat("a", maxIndexNameLength+1)), ErrInvalidIndexName)
+	})
+}
+
 func TestIndex_AddJson(t *testing.T) {
 	doc := []byte(jsonExample)
 	ref := defaultReferenceCreator(doc)
@@ -147,6 +297,44 @@ func TestIndex_AddJson(t *testing.T) {
 		assertIndexed(t, db, i, key, ref2)
 		assertIndexSize(t, db, i, 2)
 	})
+
+	t.Run("ok - empty value is not indexed", func(t *testing.T) {
+		i := c.NewIndex(t.Name(), NewFieldIndexer(NewJSONPath("path.part")))
+
+		err := db.Update(func(tx *bbolt.Tx) error {
+			return i.Add(testBucket(t, tx), ref, []byte(`{"path": {"part": ""}}`))
+		})
+
+		assert.NoError(t, err)
+		assertIndexSize(t, db, i, 0)
+	})
+
+	t.Run("error - key exists as value", func(t *testing.T) {
+		i := c.NewIndex(t.Name(), NewFieldIndexer(NewJSONPath("path.part")))
+
+		err := db.Update(func(tx *bbolt.Tx) error {
+			iBucket, _ := testBucket(t, tx).CreateBucketIfNotExists(i.BucketName())
+			_ = iBucket.Put([]byte("value"), []byte{})
+			return i.Add(testBucket(t, tx), ref, doc)
+		})
+
+		assert.ErrorIs(t, err, bbolt.ErrIncompatibleValue)
+	})
+
+	t.Run("error - key exists as value in compound index", func(t *testing.T) {
+		i := c.NewIndex(t.Name(),
+			NewFieldIndexer(NewJSONPath("path.part")),
+			NewFieldIndexer(NewJSONPath("path.parts")),
+		)
+
+		err := db.Update(func(tx *bbolt.Tx) error {
+			iBucket, _ := testBucket(t, tx).CreateBucketIfNotExists(i.BucketName())
+			_ = iBucket.Put(ComposeKey(Key("value"), Key("value3")), []byte{})
+			return i.Add(testBucket(t, tx), ref, doc)
+		})
+
+		assert.ErrorIs(t, err, bbolt.ErrIncompatibleValue)
+	})
 }
 
 func TestIndex_Delete(t *testing.T) {
@@ -292,6 +480,27 @@ func TestIndex_IsMatch(t *testing.T) {
 
 		assert.Equal(t, 0.0, f)
 	})
+
+	t.Run("ok - custom query part", func(t *testing.T) {
+		f := i.IsMatch(
+			New(customPart{queryPath: key}))
+
+		assert.Equal(t, 1.0, f)
+	})
+
+	t.Run("ok - no match on suffix", func(t *testing.T) {
+		f := i.IsMatch(
+			New(Suffix(key, valueAsScalar)))
+
+		assert.Equal(t, 0.0, f)
+	})
+
+	t.Run("ok - In scores like Eq", func(t *testing.T) {
+		f := i.IsMatch(
+			New(In(key, valueAsScalar, MustParseScalar("other"))))
+
+		assert.Equal(t, 1.0, f)
+	})
 }
 
 func TestIndex_Find(t *testing.T) {
@@ -495,7 +704,7 @@ func TestIndex_findR(t *testing.T) {
 		// by passing the value to be found as latest cursor value, it should skip over the results
 		err := db.View(func(tx *bbolt.Tx) error {
 			cursor := testBucket(t, tx).Bucket(i.BucketName()).Cursor()
-			_, err := findR(cursor, []byte{}, matchers, foundFunc, []byte{}, 0)
+			_, err := findR(cursor, []byte{}, matchers, foundFunc, []byte{}, 0, 1)
 			return err
 		})
 
@@ -509,13 +718,155 @@ func TestIndex_findR(t *testing.T) {
 		// by passing the value to be found as latest cursor value, it should skip over the results
 		err := db.View(func(tx *bbolt.Tx) error {
 			cursor := testBucket(t, tx).Bucket(i.BucketName()).Cursor()
-			_, err := findR(cursor, []byte{}, matchers, foundFunc, []byte("valuf"), 0)
+			_, err := findR(cursor, []byte{}, matchers, foundFunc, []byte("valuf"), 0, 1)
 			return err
 		})
 
 		assert.NoError(t, err)
 		assert.False(t, found)
 	})
+
+	t.Run("ok - backtracking when the nested search doesn't advance the cursor", func(t *testing.T) {
+		// keys from the "backtracking in search broken" issue: 0645 nil, 068 0682726, 0682 nil
+		db, c := testCollection(t)
+		first := NewJSONPath("first")
+		compound := c.NewIndex("compound", NewFieldIndexer(first), NewFieldIndexer(NewJSONPath("second"))).(*index)
+		docs := []Document{
+			[]byte(`{"first": "0645"}`),
+			[]byte(`{"first": "068", "second": "0682726"}`),
+			[]byte(`{"first": "0682"}`),
+		}
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			b := testBucket(t, tx)
+			for _, doc := range docs {
+				if err := compound.Add(b, defaultReferenceCreator(doc), doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		refs := make(map[string]struct{})
+
+		err := db.View(func(tx *bbolt.Tx) error {
+			return compound.Iterate(testBucket(t, tx), New(Prefix(first, MustParseScalar("06"))), func(key Reference, value []byte) error {
+				refs[string(value)] = struct{}{}
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, refs, 3)
+	})
+
+	t.Run("ok - 64 index parts", func(t *testing.T) {
+		db, c := testCollection(t)
+		parts := make([]FieldIndexer, 64)
+		fields := make([]string, 64)
+		query := New(Prefix(NewJSONPath("f0"), MustParseScalar("a")))
+		for j := range parts {
+			path := NewJSONPath(fmt.Sprintf("f%d", j))
+			parts[j] = NewFieldIndexer(path)
+			fields[j] = fmt.Sprintf(`"f%d": "a%d"`, j, j)
+			if j > 0 {
+				query = query.And(Prefix(path, MustParseScalar("a")))
+			}
+		}
+		compound := c.NewIndex("compound", parts...).(*index)
+		doc := Document("{" + strings.Join(fields, ",") + "}")
+		other := Document(`{"f0": "a0", "f1": "b1"}`)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			b := testBucket(t, tx)
+			_ = compound.Add(b, defaultReferenceCreator(other), other)
+			return compound.Add(b, defaultReferenceCreator(doc), doc)
+		})
+		var refs []Reference
+
+		err := db.View(func(tx *bbolt.Tx) error {
+			return compound.Iterate(testBucket(t, tx), query, func(key Reference, value []byte) error {
+				refs = append(refs, value)
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Reference{defaultReferenceCreator(doc)}, refs)
+	})
+
+	t.Run("ok - In seeks every value in ascending order", func(t *testing.T) {
+		db, c := testCollection(t)
+		status := NewJSONPath("status")
+		i := c.NewIndex("status", NewFieldIndexer(status)).(*index)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			b := testBucket(t, tx)
+			for _, value := range []string{"active", "deleted", "pending", "revoked", "suspended"} {
+				doc := []byte(fmt.Sprintf(`{"status": "%s"}`, value))
+				if err := i.Add(b, defaultReferenceCreator(doc), doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		q := New(In(status, MustParseScalar("suspended"), MustParseScalar("active"), MustParseScalar("pending")))
+		var keys []string
+
+		err := db.View(func(tx *bbolt.Tx) error {
+			return i.Iterate(testBucket(t, tx), q, func(key Reference, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"active", "pending", "suspended"}, keys)
+		matchers := i.matchers(q.parts)
+		assert.Equal(t, []Scalar{MustParseScalar("active"), MustParseScalar("pending"), MustParseScalar("suspended")}, matchers[0].terms)
+	})
+
+	t.Run("ok - Or with exclusive bounds", func(t *testing.T) {
+		db, c := testCollection(t)
+		status := NewJSONPath("status")
+		i := c.NewIndex("status", NewFieldIndexer(status)).(*index)
+		_ = db.Update(func(tx *bbolt.Tx) error {
+			b := testBucket(t, tx)
+			for _, value := range []string{"a", "m", "n", "z"} {
+				doc := []byte(fmt.Sprintf(`{"status": "%s"}`, value))
+				if err := i.Add(b, defaultReferenceCreator(doc), doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		a, m, n := MustParseScalar("a"), MustParseScalar("m"), MustParseScalar("n")
+		queries := map[string]Query{
+			"greater than":                    New(Or(GreaterThan(status, m))),
+			"less than":                       New(Or(LessThan(status, m))),
+			"equal and greater than":          New(Or(Eq(status, a), GreaterThan(status, m))),
+			"less than and equal":             New(Or(LessThan(status, m), Eq(status, n))),
+			"equal and greater than the same": New(Or(Eq(status, m), GreaterThan(status, m))),
+		}
+		expected := map[string][]string{
+			"greater than":                    {"n", "z"},
+			"less than":                       {"a"},
+			"equal and greater than":          {"a", "n", "z"},
+			"less than and equal":             {"a", "n"},
+			"equal and greater than the same": {"m", "n", "z"},
+		}
+		for name, q := range queries {
+			t.Run(name, func(t *testing.T) {
+				var keys []string
+
+				err := db.View(func(tx *bbolt.Tx) error {
+					return i.Iterate(testBucket(t, tx), q, func(key Reference, value []byte) error {
+						keys = append(keys, string(key))
+						return nil
+					})
+				})
+
+				assert.NoError(t, err)
+				assert.Equal(t, expected[name], keys)
+			})
+		}
+	})
 }
 
 func TestIndex_addRefToBucket(t *testing.T) {
@@ -614,7 +965,7 @@ func TestIndex_QueryPartsOutsideIndex(t *testing.T) {
 	).(*index)
 
 	t.Run("returns empty list when all parts in index", func(t *testing.T) {
-		additional := i.QueryPartsOutsideIndex(
+		additional, _ := i.QueryPartsOutsideIndex(
 			New(Eq(key2, valueAsScalar)).
 				And(Eq(key, valueAsScalar)))
 
@@ -622,14 +973,14 @@ func TestIndex_QueryPartsOutsideIndex(t *testing.T) {
 	})
 
 	t.Run("returns all parts when none match index", func(t *testing.T) {
-		additional := i.QueryPartsOutsideIndex(
+		additional, _ := i.QueryPartsOutsideIndex(
 			New(Eq(key2, valueAsScalar)))
 
 		assert.Len(t, additional, 1)
 	})
 
 	t.Run("returns correct params on partial index match", func(t *testing.T) {
-		additional := i.QueryPartsOutsideIndex(
+		additional, _ := i.QueryPartsOutsideIndex(
 			New(Eq(key3, valueAsScalar)).
 				And(Eq(key, valueAsScalar)))
 
@@ -640,7 +991,7 @@ func TestIndex_QueryPartsOutsideIndex(t *testing.T) {
 	})
 
 	t.Run("returns param if duplicate and is index hit", func(t *testing.T) {
-		additional := i.QueryPartsOutsideIndex(
+		additional, _ := i.QueryPartsOutsideIndex(
 			New(Eq(key, valueAsScalar)).
 				And(Eq(key, valueAsScalar)))
 
@@ -651,7 +1002,7 @@ func TestIndex_QueryPartsOutsideIndex(t *testing.T) {
 	})
 
 	t.Run("returns all duplicates", func(t *testing.T) {
-		additional := i.QueryPartsOutsideIndex(
+		additional, _ := i.QueryPartsOutsideIndex(
 			New(Eq(key, valueAsScalar)).
 				And(Eq(key, valueAsScalar)).
 				And(Eq(key3, valueAsScalar)).
@@ -664,6 +1015,15 @@ func TestIndex_QueryPartsOutsideIndex(t *testing.T) {
 		assert.Equal(t, key3, additional[1].QueryPath())
 		assert.Equal(t, key3, additional[2].QueryPath())
 	})
+
+	t.Run("error - part without path", func(t *testing.T) {
+		additional, err := i.QueryPartsOutsideIndex(
+			New(Eq(key, valueAsScalar)).
+				And(Eq(nil, valueAsScalar)))
+
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+		assert.Nil(t, additional)
+	})
 }
 
 func TestIndex_Keys(t *testing.T) {
@@ -752,4 +1112,41 @@ func TestIndex_Keys(t *testing.T) {
 
 		assert.Error(t, err)
 	})
+
+	t.Run("ok - incorrect document with SkipOnErrorOption", func(t *testing.T) {
+		_, c, i := testIndex(t)
+		buf := new(bytes.Buffer)
+		c.logger = slog.New(slog.NewTextHandler(buf, nil))
+		ip := NewFieldIndexer(NewJSONPath("path.part"), SkipOnErrorOption())
+
+		keys, err := i.Keys(ip, []byte("}"))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, keys, 0)
+		assert.Contains(t, buf.String(), fmt.Sprintf(`level=WARN msg="values can't be collected, field isn't indexed for the document" index=%s field=path.part error=`, i.Name()))
+	})
+}
+
+// customPart is a QueryPart implemented outside the known set of query parts
+type customPart struct {
+	queryPath QueryPath
+}
+
+func (c customPart) Equals(other QueryPathComparable) bool {
+	return c.queryPath.Equals(other.QueryPath())
+}
+
+func (c customPart) QueryPath() QueryPath {
+	return c.queryPath
+}
+
+func (c customPart) Seek() Scalar {
+	return BytesScalar{}
+}
+
+func (c customPart) Condition(key Key, _ Transform) bool {
+	return len(key) > 0
 }
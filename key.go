@@ -19,7 +19,14 @@
 
 package leia
 
-import "bytes"
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 // Key is used as DB key type
 type Key []byte
@@ -48,14 +55,11 @@ func ComposeKey(current Key, additional Key) Key {
 		return additional
 	}
 
-	c := current.Split()
-	b := make([][]byte, len(c))
-	for i, k := range c {
-		b[i] = k
-	}
-
-	b = append(b, additional)
-	return bytes.Join(b, []byte{KeyDelimiter})
+	// there's no escaping, a KeyDelimiter within a part can only be split back correctly when it's in the last part, see SplitN
+	key := make(Key, 0, len(current)+1+len(additional))
+	key = append(key, current...)
+	key = append(key, KeyDelimiter)
+	return append(key, additional...)
 }
 
 // Split splits a compound key into parts
@@ -69,3 +73,53 @@ func (k Key) Split() []Key {
 
 	return nk
 }
+
+// SplitN splits a compound key into at most n parts, the last part holds the remainder of the key.
+// Keys aren't escaped, so a KeyDelimiter within a value is only kept when it's in the last part.
+func (k Key) SplitN(n int) []Key {
+	s := bytes.SplitN(k, []byte{KeyDelimiter}, n)
+	var nk = make([]Key, len(s))
+
+	for i, si := range s {
+		nk[i] = si
+	}
+
+	return nk
+}
+
+// FormatKey returns a human-readable representation of a (compound) key, useful for logging and debugging.
+// Each part of the key is formatted as a quoted string if it's printable text and as hex otherwise.
+// Parts are separated by " | ", eg: "value" | 0x3ff8000000000000 | 0x0102
+// fieldCount is the number of fields of the index, so a KeyDelimiter within the value of the last field doesn't result in an extra part.
+// When it's 0, the key is split at every KeyDelimiter.
+func FormatKey(key Key, fieldCount int) string {
+	parts := key.Split()
+	if fieldCount > 0 {
+		parts = key.SplitN(fieldCount)
+	}
+	formatted := make([]string, len(parts))
+	for i, part := range parts {
+		formatted[i] = formatKeyPart(part)
+	}
+	return strings.Join(formatted, " | ")
+}
+
+// formatKeyPart formats printable text as a quoted string and other values, like numbers, as hex
+func formatKeyPart(part Key) string {
+	if isPrintable(part) {
+		return fmt.Sprintf("%q", string(part))
+	}
+	return "0x" + hex.EncodeToString(part)
+}
+
+func isPrintable(part Key) bool {
+	if !utf8.Valid(part) {
+		return false
+	}
+	for _, r := range string(part) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -62,6 +62,16 @@ func TestComposeKey(t *testing.T) {
 	})
 }
 
+func TestComposeKey_Binary(t *testing.T) {
+	k1 := Key{0x01, KeyDelimiter, 0x02}
+	k2 := Key{0xff}
+
+	k := ComposeKey(k1, k2)
+
+	assert.Equal(t, Key{0x01, KeyDelimiter, 0x02, KeyDelimiter, 0xff}, k)
+	assert.Equal(t, Key{0x01, KeyDelimiter, 0x02}, k1)
+}
+
 func TestKey_Split(t *testing.T) {
 	t.Run("ok - single key", func(t *testing.T) {
 		s := Key("first").Split()
@@ -81,3 +91,51 @@ func TestKey_Split(t *testing.T) {
 		assert.Equal(t, k2, s[1])
 	})
 }
+
+func TestKey_SplitN(t *testing.T) {
+	t.Run("ok - delimiter in last part", func(t *testing.T) {
+		c := ComposeKey(Key("first"), Key{0x01, KeyDelimiter, 0x02})
+
+		s := c.SplitN(2)
+
+		assert.Equal(t, []Key{Key("first"), {0x01, KeyDelimiter, 0x02}}, s)
+	})
+
+	t.Run("ok - fewer parts", func(t *testing.T) {
+		s := Key("first").SplitN(2)
+
+		assert.Equal(t, []Key{Key("first")}, s)
+	})
+}
+
+func TestFormatKey(t *testing.T) {
+	t.Run("ok - string", func(t *testing.T) {
+		assert.Equal(t, `"value"`, FormatKey(Key("value"), 1))
+	})
+
+	t.Run("ok - empty", func(t *testing.T) {
+		assert.Equal(t, `""`, FormatKey(Key{}, 1))
+	})
+
+	t.Run("ok - numbers as hex", func(t *testing.T) {
+		assert.Equal(t, "0x3ff8000000000000", FormatKey(Float64Scalar(1.5).Bytes(), 1))
+		assert.Equal(t, "0x8000000000000001", FormatKey(Int64Scalar(1).Bytes(), 1))
+	})
+
+	t.Run("ok - bytes", func(t *testing.T) {
+		assert.Equal(t, "0x01", FormatKey(BoolScalar(true).Bytes(), 1))
+	})
+
+	t.Run("ok - compound key", func(t *testing.T) {
+		k := ComposeKey(ComposeKey(Key("value"), Float64Scalar(1.5).Bytes()), []byte{1, 2})
+
+		assert.Equal(t, `"value" | 0x3ff8000000000000 | 0x0102`, FormatKey(k, 3))
+	})
+
+	t.Run("ok - delimiter within the last field", func(t *testing.T) {
+		k := ComposeKey(Key("value"), []byte{1, KeyDelimiter, 2})
+
+		assert.Equal(t, `"value" | 0x011002`, FormatKey(k, 2))
+		assert.Equal(t, `"value" | 0x01 | 0x02`, FormatKey(k, 0))
+	})
+}
@@ -0,0 +1,108 @@
+/*
+ * go-leia
+ * Copyright (C) 2021 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// cachingDocumentLoader is a ld.DocumentLoader that keeps at most maxEntries loaded documents for ttl, evicting the least recently used.
+// Failed loads aren't cached.
+type cachingDocumentLoader struct {
+	next       ld.DocumentLoader
+	maxEntries int
+	ttl        time.Duration
+	mutex      sync.Mutex
+	// entries holds *cacheEntry values, the most recently used at the front
+	entries *list.List
+	byURL   map[string]*list.Element
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	url      string
+	document *ld.RemoteDocument
+	expires  time.Time
+}
+
+func newCachingDocumentLoader(next ld.DocumentLoader, maxEntries int, ttl time.Duration) *cachingDocumentLoader {
+	return &cachingDocumentLoader{
+		next:       next,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    list.New(),
+		byURL:      map[string]*list.Element{},
+		now:        time.Now,
+	}
+}
+
+// LoadDocument returns the cached document for the URL or loads it using the wrapped loader.
+func (c *cachingDocumentLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	if document := c.get(u); document != nil {
+		return document, nil
+	}
+
+	// loaded without holding the lock, so a slow fetch doesn't block other URLs
+	document, err := c.next.LoadDocument(u)
+	if err != nil {
+		return nil, err
+	}
+	c.put(u, document)
+	return document, nil
+}
+
+func (c *cachingDocumentLoader) get(u string) *ld.RemoteDocument {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.byURL[u]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil
+	}
+	c.entries.MoveToFront(element)
+	return entry.document
+}
+
+func (c *cachingDocumentLoader) put(u string, document *ld.RemoteDocument) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.byURL[u]; ok {
+		c.remove(element)
+	}
+	c.byURL[u] = c.entries.PushFront(&cacheEntry{url: u, document: document, expires: c.now().Add(c.ttl)})
+	for c.entries.Len() > c.maxEntries {
+		c.remove(c.entries.Back())
+	}
+}
+
+func (c *cachingDocumentLoader) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.byURL, element.Value.(*cacheEntry).url)
+}
@@ -0,0 +1,110 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingDocumentLoader counts the loads per URL
+type countingDocumentLoader struct {
+	loads map[string]int
+	err   error
+}
+
+func (c *countingDocumentLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	c.loads[u]++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &ld.RemoteDocument{DocumentURL: u}, nil
+}
+
+func TestCachingDocumentLoader_LoadDocument(t *testing.T) {
+	setup := func() (*countingDocumentLoader, *cachingDocumentLoader, *time.Time) {
+		next := &countingDocumentLoader{loads: map[string]int{}}
+		loader := newCachingDocumentLoader(next, 2, time.Minute)
+		now := time.Now()
+		loader.now = func() time.Time { return now }
+		return next, loader, &now
+	}
+
+	t.Run("ok - cached", func(t *testing.T) {
+		next, loader, _ := setup()
+
+		first, err := loader.LoadDocument("a")
+		if !assert.NoError(t, err) {
+			return
+		}
+		second, err := loader.LoadDocument("a")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Same(t, first, second)
+		assert.Equal(t, 1, next.loads["a"])
+	})
+
+	t.Run("ok - reloaded after ttl", func(t *testing.T) {
+		next, loader, now := setup()
+
+		_, _ = loader.LoadDocument("a")
+		*now = now.Add(time.Minute)
+		_, err := loader.LoadDocument("a")
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, next.loads["a"])
+	})
+
+	t.Run("ok - least recently used is evicted", func(t *testing.T) {
+		next, loader, _ := setup()
+
+		_, _ = loader.LoadDocument("a")
+		_, _ = loader.LoadDocument("b")
+		_, _ = loader.LoadDocument("a")
+		_, _ = loader.LoadDocument("c")
+		_, _ = loader.LoadDocument("a")
+		_, _ = loader.LoadDocument("b")
+
+		assert.Equal(t, 1, next.loads["a"])
+		assert.Equal(t, 2, next.loads["b"])
+		assert.LessOrEqual(t, loader.entries.Len(), 2)
+	})
+
+	t.Run("error - failed load isn't cached", func(t *testing.T) {
+		next, loader, _ := setup()
+		next.err = errors.New("b00m!")
+
+		_, err := loader.LoadDocument("a")
+		assert.EqualError(t, err, "b00m!")
+		next.err = nil
+		_, err = loader.LoadDocument("a")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, next.loads["a"])
+	})
+}
@@ -20,7 +20,12 @@
 package leia
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
@@ -29,6 +34,14 @@ import (
 type queryPlan interface {
 	// execute the plan call the DocumentWalker for each matching document
 	execute(walker DocumentWalker) error
+	// documentScanner creates the documentScanFn that filters the documents found by the plan and calls the DocumentWalker.
+	// It must be called before a transaction is started.
+	documentScanner(walker DocumentWalker) (documentScanFn, error)
+	// executeTx executes the plan within the given transaction, the documentScanFn is called for each document found by the plan.
+	// It may return errLimitReached when the limit of the query has been reached.
+	executeTx(tx *bbolt.Tx, scanner documentScanFn) error
+	// Explain returns a human-readable description of the plan, the chosen index and how the query parts are resolved
+	Explain() string
 }
 
 // queryPlanBase contains elements common for each query plan
@@ -61,42 +74,119 @@ type ReferenceScanFn func(key []byte, value []byte) error
 type documentScanFn func(key []byte, value []byte) error
 
 func (f fullTableScanQueryPlan) execute(walker DocumentWalker) error {
-	return f.collection.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket([]byte(f.collection.name))
-		if bucket == nil {
-			// no bucket means no docs
-			return nil
+	_, err := f.executeFrom(nil, walker)
+	return err
+}
+
+func (f fullTableScanQueryPlan) Explain() string {
+	var sb strings.Builder
+	sb.WriteString("full table scan\n")
+	f.collection.indexLock.RLock()
+	indices := f.collection.indexList
+	f.collection.indexLock.RUnlock()
+	if len(indices) == 0 {
+		sb.WriteString("reason: the collection has no indices\n")
+	} else {
+		sb.WriteString("reason: no index matches the query\n")
+		for _, index := range indices {
+			sb.WriteString(fmt.Sprintf("index %q: score %v\n", index.Name(), index.IsMatch(f.query)))
 		}
-		bucket = bucket.Bucket(documentCollectionByteRef())
-		if bucket == nil {
-			// no bucket means no docs
-			return nil
+	}
+	for _, part := range f.query.parts {
+		if !isIndexable(part) {
+			sb.WriteString(fmt.Sprintf("not indexable: %s\n", describeQueryPart(part)))
 		}
+	}
+	f.explainParts(&sb, "filtered", f.query.parts)
+	f.explainPaging(&sb)
+	return sb.String()
+}
 
-		parts := make([]QueryPart, 0)
-		if f.query.parts != nil {
-			parts = f.query.parts
-		}
-		scanner := resultScanner(parts, walker, f.collection)
+func (f fullTableScanQueryPlan) documentScanner(walker DocumentWalker) (documentScanFn, error) {
+	parts := make([]QueryPart, 0)
+	if f.query.parts != nil {
+		parts = f.query.parts
+	}
+	return resultScanner(parts, pagingWalker(f.query, walker), f.collection), nil
+}
 
-		cursor := bucket.Cursor()
-		for ref, bytes := cursor.First(); bytes != nil; ref, bytes = cursor.Next() {
-			if err := scanner(ref, bytes); err != nil {
-				return err
-			}
-		}
-		return nil
+func (f fullTableScanQueryPlan) executeTx(tx *bbolt.Tx, scanner documentScanFn) error {
+	_, err := f.scan(tx, nil, scanner)
+	return err
+}
+
+// executeFrom scans the documents in order of reference, starting after the bookmark (the reference of the last processed document).
+// It returns the reference of the last processed document.
+func (f fullTableScanQueryPlan) executeFrom(bookmark []byte, walker DocumentWalker) ([]byte, error) {
+	start := time.Now()
+	count := 0
+	scanner, _ := f.documentScanner(countingWalker(walker, &count))
+
+	err := f.collection.db.View(func(tx *bbolt.Tx) (err error) {
+		bookmark, err = f.scan(tx, bookmark, scanner)
+		return err
 	})
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+	if err == nil {
+		f.collection.emit(EventTableScan, start, nil, count)
+	}
+	return bookmark, err
+}
+
+// scan calls the documentScanFn for the documents in order of reference, starting after the bookmark.
+// It returns the reference of the last processed document.
+func (f fullTableScanQueryPlan) scan(tx *bbolt.Tx, bookmark []byte, scanner documentScanFn) ([]byte, error) {
+	f.collection.stats.fullTableScan.Add(1)
+	bucket := tx.Bucket([]byte(f.collection.name))
+	if bucket == nil {
+		// no bucket means no docs
+		return bookmark, nil
+	}
+	bucket = bucket.Bucket(f.collection.documentCollectionByteRef())
+	if bucket == nil {
+		// no bucket means no docs
+		return bookmark, nil
+	}
+
+	cursor := bucket.Cursor()
+	ref, doc := cursor.First()
+	if bookmark != nil {
+		// continue after the last processed document
+		ref, doc = cursor.Seek(bookmark)
+		if bytes.Equal(ref, bookmark) {
+			ref, doc = cursor.Next()
+		}
+	}
+	for ; doc != nil; ref, doc = cursor.Next() {
+		err := scanner(ref, doc)
+		if err == nil || errors.Is(err, ErrStopIteration) || errors.Is(err, errLimitReached) {
+			// copy the ref, it's only valid during the transaction
+			bookmark = append([]byte{}, ref...)
+		}
+		if err != nil {
+			return bookmark, err
+		}
+	}
+	return bookmark, nil
 }
 
-func (i indexScanQueryPlan) execute(walker ReferenceScanFn) error {
-	queryParts := i.index.QueryPartsOutsideIndex(i.query)
+func (i indexScanQueryPlan) execute(ctx context.Context, walker ReferenceScanFn) error {
+	queryParts, err := i.index.QueryPartsOutsideIndex(i.query)
+	if err != nil {
+		return err
+	}
 	if len(queryParts) != 0 {
 		return errors.New("no index with exact match to query found")
 	}
+	i.collection.stats.indexScan.Add(1)
+	i.collection.log().Debug("index lookup", "index", i.index.Name(), "query", describeQuery(i.query))
+	start := time.Now()
+	count := 0
 
 	// do the IndexScan
-	return i.collection.db.View(func(tx *bbolt.Tx) error {
+	err = i.collection.db.View(func(tx *bbolt.Tx) error {
 		// nil is not possible since adding an index creates the iBucket
 		iBucket := tx.Bucket([]byte(i.collection.name))
 		if iBucket == nil { // nothing added yet
@@ -104,37 +194,150 @@ func (i indexScanQueryPlan) execute(walker ReferenceScanFn) error {
 		}
 
 		// expander expands the index entry to the actual document
-		expander := indexEntryExpander(walker)
+		expander := indexEntryExpander(func(key []byte, value []byte) error {
+			// stop iteration when needed
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			count++
+			return walker(key, value)
+		})
 
 		return i.index.Iterate(iBucket, i.query, expander)
 	})
+	if err == nil {
+		i.collection.emit(EventIndexScan, start, nil, count)
+	}
+	return err
 }
 
 func (i resultScanQueryPlan) execute(walker DocumentWalker) error {
-	queryParts := i.index.QueryPartsOutsideIndex(i.query)
+	start := time.Now()
+	count := 0
+	resultScan, err := i.documentScanner(countingWalker(walker, &count))
+	if err != nil {
+		return err
+	}
 
 	// do the IndexScan
-	return i.collection.db.View(func(tx *bbolt.Tx) error {
-		docBucket := i.collection.documentBucket(tx)
-		if docBucket == nil {
-			// no bucket means no docs
-			return nil
+	err = i.collection.db.View(func(tx *bbolt.Tx) error {
+		return i.executeTx(tx, resultScan)
+	})
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+	if err == nil {
+		i.collection.emit(EventIndexScan, start, nil, count)
+	}
+	return err
+}
+
+func (i resultScanQueryPlan) Explain() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("index scan using index %q: score %v\n", i.index.Name(), i.index.IsMatch(i.query)))
+	outside, err := i.index.QueryPartsOutsideIndex(i.query)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("error: %s\n", err))
+		return sb.String()
+	}
+	// the parts that are resolved by the index are only known for indices created by the collection
+	if idx, ok := i.index.(*index); ok {
+		matchingParts := idx.matchingParts(i.query)
+		i.explainParts(&sb, "inside index", matchingParts)
+		sb.WriteString("seek:\n")
+		for j, m := range idx.matchers(matchingParts) {
+			sb.WriteString(fmt.Sprintf("  - %s: %s\n", idx.indexParts[j].QueryPath(), describeTerms(m.terms)))
 		}
+	}
+	i.explainParts(&sb, "outside index", outside)
+	i.explainPaging(&sb)
+	return sb.String()
+}
 
-		// nil is not possible since adding an index creates the iBucket
-		iBucket := tx.Bucket([]byte(i.collection.name))
+func (i resultScanQueryPlan) documentScanner(walker DocumentWalker) (documentScanFn, error) {
+	queryParts, err := i.index.QueryPartsOutsideIndex(i.query)
+	if err != nil {
+		return nil, err
+	}
 
-		// resultScanner takes the refs from the indexScan, resolves the document and applies the remaining queryParts
-		resultScan := resultScanner(queryParts, walker, i.collection)
+	if len(queryParts) > 0 {
+		i.collection.log().Debug("result scan", "index", i.index.Name(), "filter", describeQuery(Query{parts: queryParts}))
+	}
+	// resultScanner takes the refs from the indexScan, resolves the document and applies the remaining queryParts
+	return resultScanner(queryParts, pagingWalker(i.query, walker), i.collection), nil
+}
 
-		// fetcher expands references to documents, for each document it calls the resultScan
-		fetcher := documentFetcher(docBucket, resultScan)
+func (i resultScanQueryPlan) executeTx(tx *bbolt.Tx, resultScan documentScanFn) error {
+	i.collection.stats.indexScan.Add(1)
+	i.collection.log().Debug("index lookup", "index", i.index.Name(), "query", describeQuery(i.query))
+	docBucket := i.collection.documentBucket(tx)
+	if docBucket == nil {
+		// no bucket means no docs
+		return nil
+	}
 
-		// expander expands the index entry to the actual document
-		expander := indexEntryExpander(fetcher)
+	// nil is not possible since adding an index creates the iBucket
+	iBucket := tx.Bucket([]byte(i.collection.name))
 
-		return i.index.Iterate(iBucket, i.query, expander)
-	})
+	// fetcher expands references to documents, for each document it calls the resultScan
+	fetcher := documentFetcher(docBucket, resultScan)
+
+	// expander expands the index entry to the actual document
+	expander := indexEntryExpander(fetcher)
+
+	return i.index.Iterate(iBucket, i.query, expander)
+}
+
+// explainParts writes the query parts to the builder
+func (q queryPlanBase) explainParts(sb *strings.Builder, title string, parts []QueryPart) {
+	sb.WriteString(fmt.Sprintf("%s:\n", title))
+	for _, part := range parts {
+		sb.WriteString(fmt.Sprintf("  - %s\n", describeQueryPart(part)))
+	}
+}
+
+// explainPaging writes the limit and skip of the query to the builder
+func (q queryPlanBase) explainPaging(sb *strings.Builder) {
+	if q.query.limit > 0 {
+		sb.WriteString(fmt.Sprintf("limit: %d\n", q.query.limit))
+	}
+	if q.query.skip > 0 {
+		sb.WriteString(fmt.Sprintf("skip: %d\n", q.query.skip))
+	}
+}
+
+// errLimitReached is used to stop the iteration when the limit of a query has been reached
+var errLimitReached = errors.New("query limit reached")
+
+// pagingWalker creates a DocumentWalker that skips the first results and stops the iteration with errLimitReached when the limit of the query is reached
+func pagingWalker(query Query, walker DocumentWalker) DocumentWalker {
+	if query.skip == 0 && query.limit == 0 {
+		return walker
+	}
+	skipped := 0
+	returned := 0
+	return func(key Reference, value []byte) error {
+		if skipped < query.skip {
+			skipped++
+			return nil
+		}
+		if err := walker(key, value); err != nil {
+			return err
+		}
+		returned++
+		if query.limit > 0 && returned >= query.limit {
+			return errLimitReached
+		}
+		return nil
+	}
+}
+
+// countingWalker creates a DocumentWalker that counts the number of calls before calling the given DocumentWalker
+func countingWalker(walker DocumentWalker, count *int) DocumentWalker {
+	return func(key Reference, value []byte) error {
+		*count++
+		return walker(key, value)
+	}
 }
 
 // documentFetcher creates a ReferenceScanFn which is called with a reference, fetches the document and calls the documentScanFn
@@ -152,18 +355,42 @@ func documentFetcher(documentCollection *bbolt.Bucket, docWalker documentScanFn)
 
 }
 
+// scalarChecker is implemented by query parts that can only be applied to certain types of values
+type scalarChecker interface {
+	checkScalar(value Scalar) error
+}
+
 // resultScanner returns a resultScannerFn. For each call it will compare the document against the given queryParts.
-// If conditions are met, it'll call the DocumentWalker
+// If conditions are met, it'll call the DocumentWalker.
+// Values are transformed by the Transform of a FieldIndexer with the same path, like they are when stored in an index.
+// The transforms are looked up when the resultScanner is created, which must be done before a transaction is started.
 func resultScanner(queryParts []QueryPart, walker DocumentWalker, collection *collection) documentScanFn {
-	return func(ref []byte, doc []byte) error {
+	transforms := make([]Transform, len(queryParts))
+	for j, part := range queryParts {
+		transforms[j] = collection.transformFor(part)
+	}
+	return func(ref []byte, data []byte) error {
+		collection.stats.documentsFetched.Add(1)
+		doc, err := decodeDocument(data)
+		if err != nil {
+			return err
+		}
 	outer:
-		for _, part := range queryParts {
+		for j, part := range queryParts {
 			keys, err := collection.ValuesAtPath(doc, part.QueryPath())
 			if err != nil {
 				return err
 			}
 			for _, k := range keys {
-				if part.Condition(k.Bytes(), nil) {
+				if checker, ok := part.(scalarChecker); ok {
+					if err := checker.checkScalar(k); err != nil {
+						return err
+					}
+				}
+				if transform := transforms[j]; transform != nil {
+					k = transform(k)
+				}
+				if part.Condition(k.Bytes(), transforms[j]) {
 					continue outer
 				}
 			}
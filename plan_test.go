@@ -20,7 +20,9 @@
 package leia
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,9 +46,25 @@ func TestFullTableScanQueryPlan_execute(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("ok - reads the bucket of the named collection", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		c := s.JSONCollection("named")
+		_ = s.JSONCollection("other").Add(context.TODO(), []Document{[]byte(jsonExample2)})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		docs, err := c.Find(context.Background(), New(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		if assert.Len(t, docs, 1) {
+			assert.Equal(t, Document(exampleDoc), docs[0])
+		}
+	})
+
 	t.Run("error - when walker returns an error", func(t *testing.T) {
 		_, c := testCollection(t)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 		queryPlan := fullTableScanQueryPlan{
 			queryPlanBase: queryPlanBase{
 				collection: c,
@@ -72,7 +90,7 @@ func TestIndexScanQueryPlan_Execute(t *testing.T) {
 			index: i,
 		}
 
-		err := queryPlan.execute(func(key []byte, value []byte) error {
+		err := queryPlan.execute(context.Background(), func(key []byte, value []byte) error {
 			// should not be called
 			return errors.New("failed in loop")
 		})
@@ -90,7 +108,7 @@ func TestIndexScanQueryPlan_Execute(t *testing.T) {
 			index: i,
 		}
 
-		err := queryPlan.execute(func(key []byte, value []byte) error {
+		err := queryPlan.execute(context.Background(), func(key []byte, value []byte) error {
 			// should not be called
 			return errors.New("failed")
 		})
@@ -122,10 +140,10 @@ func TestResultScanQueryPlan_Execute(t *testing.T) {
 func TestDocumentFetcher(t *testing.T) {
 	t.Run("ok - nil bytes passed", func(t *testing.T) {
 		db, c := testCollection(t)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 
 		err := db.View(func(tx *bbolt.Tx) error {
-			fetcher := documentFetcher(tx.Bucket(documentCollectionByteRef()), func(_ []byte, _ []byte) error {
+			fetcher := documentFetcher(tx.Bucket(c.documentCollectionByteRef()), func(_ []byte, _ []byte) error {
 				return errors.New("failed")
 			})
 
@@ -153,16 +171,62 @@ func TestResultScanner(t *testing.T) {
 
 	t.Run("error - non comparable entry", func(t *testing.T) {
 		db, c := testCollection(t)
-		_ = c.Add([]Document{exampleDoc})
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
 
-		err := db.View(func(tx *bbolt.Tx) error {
-			scanner := resultScanner([]QueryPart{Eq(NewJSONPath("main.nesting"), valueAsScalar)}, func(_ Reference, _ []byte) error {
-				return errors.New("failed")
-			}, c)
+		scanner := resultScanner([]QueryPart{Eq(NewJSONPath("main.nesting"), valueAsScalar)}, func(_ Reference, _ []byte) error {
+			return errors.New("failed")
+		}, c)
 
+		err := db.View(func(tx *bbolt.Tx) error {
 			return scanner(nil, bytes)
 		})
 
 		assert.EqualError(t, err, "type at path not supported for indexing: {\n\t\t\t\"type\": \"bird\",\n\t\t\t\"nice\": false\n\t\t}")
 	})
+
+	t.Run("ok - uses the value collector of the collection", func(t *testing.T) {
+		c := collection{
+			valueCollector: JSONLDValueCollector,
+		}
+		count := 0
+
+		scanner := resultScanner([]QueryPart{Eq(NewIRIPath("http://example.com/name"), MustParseScalar("Jane Doe"))}, func(_ Reference, _ []byte) error {
+			count++
+			return nil
+		}, &c)
+		err := scanner(nil, []byte(jsonLDExample))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - applies the transform of an indexed field", func(t *testing.T) {
+		_, c := testCollection(t)
+		name := NewJSONPath("name")
+		_ = c.AddIndex(c.NewIndex("name", NewFieldIndexer(name, TransformerOption(ToLower))))
+		count := 0
+
+		scanner := resultScanner([]QueryPart{Prefix(name, MustParseScalar("JO"))}, func(_ Reference, _ []byte) error {
+			count++
+			return nil
+		}, c)
+		err := scanner(nil, []byte(`{"name": "John"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("ok - no transform for a field that isn't indexed", func(t *testing.T) {
+		_, c := testCollection(t)
+		count := 0
+
+		scanner := resultScanner([]QueryPart{Prefix(NewJSONPath("name"), MustParseScalar("JO"))}, func(_ Reference, _ []byte) error {
+			count++
+			return nil
+		}, c)
+		err := scanner(nil, []byte(`{"name": "John"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
 }
@@ -22,6 +22,10 @@ package leia
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 )
 
 // ErrNoQuery is returned when an empty query is given
@@ -30,7 +34,8 @@ var ErrNoQuery = errors.New("no query given")
 type jsonPath string
 
 // NewJSONPath creates a JSON path query: "person.path" or "person.children.#.path"
-// # is used to traverse arrays
+// # is used to traverse arrays.
+// gjson modifiers can be used to transform the result before it's indexed, e.g. "person.children.@reverse"
 func NewJSONPath(path string) QueryPath {
 	return jsonPath(path)
 }
@@ -39,9 +44,16 @@ func (q jsonPath) Equals(other QueryPath) bool {
 	return q == other
 }
 
+// String returns the gjson path
+func (q jsonPath) String() string {
+	return string(q)
+}
+
 // QueryPath is the interface for the query path given in queries
 type QueryPath interface {
 	Equals(other QueryPath) bool
+	// String returns a human-readable representation of the path
+	String() string
 }
 
 // iriPath represents a nested structure (or graph path) using the fully qualified IRIs
@@ -55,6 +67,21 @@ func NewIRIPath(IRIs ...string) QueryPath {
 	return iriPath{iris: IRIs}
 }
 
+// iriWildcard is the IRI in an iriPath that matches any IRI at that level of nesting.
+// It's not a valid IRI, so it can't clash with a term in a document.
+const iriWildcard = "*"
+
+// IRIPathWildcard creates a QueryPath of JSON-LD terms where a single level between before and after matches any IRI.
+// This can be used when the IRI at that level varies, eg: credential subjects with different types.
+// Values found through all matching IRIs are combined.
+func IRIPathWildcard(before []string, after []string) QueryPath {
+	iris := make([]string, 0, len(before)+len(after)+1)
+	iris = append(iris, before...)
+	iris = append(iris, iriWildcard)
+	iris = append(iris, after...)
+	return iriPath{iris: iris}
+}
+
 // IsEmpty returns true of no terms are in the list
 func (tp iriPath) IsEmpty() bool {
 	return len(tp.iris) == 0
@@ -95,6 +122,13 @@ func (tp iriPath) Equals(other QueryPath) bool {
 	return true
 }
 
+// String returns the IRIs joined by " > ", from highest to lowest
+func (tp iriPath) String() string {
+	return strings.Join(tp.iris, " > ")
+}
+
+// QueryPart is a single condition of a Query.
+// Custom QueryParts can be implemented outside of this package. The embedded QueryPathComparable is used to match a QueryPart to the FieldIndexers of an index.
 type QueryPart interface {
 	QueryPathComparable
 	// Seek returns the key for cursor.Seek
@@ -128,6 +162,24 @@ func Range(queryPath QueryPath, begin Scalar, end Scalar) QueryPart {
 	}
 }
 
+// GreaterThan creates a query part that matches values greater than the given value, the value itself is excluded.
+// Values are compared by their bytes, like Range.
+func GreaterThan(queryPath QueryPath, value Scalar) QueryPart {
+	return greaterThanPart{
+		queryPath: queryPath,
+		value:     value,
+	}
+}
+
+// LessThan creates a query part that matches values less than the given value, the value itself is excluded.
+// Values are compared by their bytes, like Range.
+func LessThan(queryPath QueryPath, value Scalar) QueryPart {
+	return lessThanPart{
+		queryPath: queryPath,
+		value:     value,
+	}
+}
+
 // NotNil creates a query part where the value must exist.
 // This is done by finding results between byte 0x0 and 0xff
 func NotNil(queryPath QueryPath) QueryPart {
@@ -145,9 +197,74 @@ func Prefix(queryPath QueryPath, value Scalar) QueryPart {
 	}
 }
 
+// Suffix creates a query part for a partial match
+// The end of a value is matched against the query.
+// Index keys are sorted from the beginning of a value, so a suffix match can't be resolved using an index.
+// It's always applied as a filter on the documents that result from a full table scan or another index.
+func Suffix(queryPath QueryPath, value Scalar) QueryPart {
+	return suffixPart{
+		queryPath: queryPath,
+		value:     value,
+	}
+}
+
+// Regex creates a query part that matches string values using the given regular expression.
+// A regular expression can't be resolved using an index, it's only used to filter the results of other query terms or a full table scan.
+// Searching returns ErrInvalidValue when the value at the path isn't a string.
+func Regex(queryPath QueryPath, pattern *regexp.Regexp) QueryPart {
+	return regexPart{
+		queryPath: queryPath,
+		pattern:   pattern,
+	}
+}
+
+// Or creates a query part that matches a value if it matches any of the given parts.
+// All parts must use the same QueryPath, it panics otherwise.
+// When the path is indexed, each part is resolved by its own seek in the index.
+func Or(parts ...QueryPart) QueryPart {
+	if len(parts) == 0 {
+		panic("Or requires at least one query part")
+	}
+	for _, part := range parts[1:] {
+		if !parts[0].QueryPath().Equals(part.QueryPath()) {
+			panic("all parts of Or must use the same query path")
+		}
+	}
+	return orPart{parts: parts}
+}
+
+// In creates a query part that matches a value if it equals any of the given values.
+// It panics if no values are given. When the path is indexed, each value is resolved by its own seek in the index.
+func In(queryPath QueryPath, values ...Scalar) QueryPart {
+	if len(values) == 0 {
+		panic("In requires at least one value")
+	}
+	sorted := make([]Scalar, 0, len(values))
+	members := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if _, ok := members[string(value.Bytes())]; ok {
+			continue
+		}
+		members[string(value.Bytes())] = struct{}{}
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return bytes.Compare(sorted[a].Bytes(), sorted[b].Bytes()) < 0
+	})
+	return inPart{
+		queryPath: queryPath,
+		values:    sorted,
+		members:   members,
+	}
+}
+
 // Query represents a query with multiple arguments
 type Query struct {
 	parts []QueryPart
+	// limit is the maximum number of results, 0 means no limit
+	limit int
+	// skip is the number of results that are skipped
+	skip int
 }
 
 func (q Query) And(part QueryPart) Query {
@@ -155,6 +272,19 @@ func (q Query) And(part QueryPart) Query {
 	return q
 }
 
+// WithLimit limits the number of results of Find and Iterate to n. A limit of 0 means no limit.
+func (q Query) WithLimit(n int) Query {
+	q.limit = n
+	return q
+}
+
+// WithSkip skips the first n results of Find and Iterate. Combined with WithLimit it can be used to page through results.
+// Results are returned in index order or, when no index is used, in order of document reference.
+func (q Query) WithSkip(n int) Query {
+	q.skip = n
+	return q
+}
+
 type eqPart struct {
 	queryPath QueryPath
 	value     Scalar
@@ -215,6 +345,69 @@ func (r rangePart) Condition(key Key, transform Transform) bool {
 	return bytes.Compare(key, eTransformed.Bytes()) <= 0
 }
 
+// exclusiveSeeker is implemented by query parts that don't match the value they seek to.
+// When an index is scanned, keys equal to the seek value are skipped instead of ending the scan, see seekTerms.
+type exclusiveSeeker interface {
+	excludesSeek()
+}
+
+type greaterThanPart struct {
+	queryPath QueryPath
+	value     Scalar
+}
+
+func (g greaterThanPart) Equals(other QueryPathComparable) bool {
+	return g.queryPath.Equals(other.QueryPath())
+}
+
+func (g greaterThanPart) QueryPath() QueryPath {
+	return g.queryPath
+}
+
+func (g greaterThanPart) Seek() Scalar {
+	return g.value
+}
+
+func (g greaterThanPart) Condition(key Key, transform Transform) bool {
+	transformed := g.value
+	if transform != nil {
+		transformed = transform(g.value)
+	}
+
+	return bytes.Compare(key, transformed.Bytes()) > 0
+}
+
+func (g greaterThanPart) excludesSeek() {}
+
+type lessThanPart struct {
+	queryPath QueryPath
+	value     Scalar
+}
+
+func (l lessThanPart) Equals(other QueryPathComparable) bool {
+	return l.queryPath.Equals(other.QueryPath())
+}
+
+func (l lessThanPart) QueryPath() QueryPath {
+	return l.queryPath
+}
+
+// Seek returns an empty key, the scan starts at the lowest key. Empty keys are used for missing values, they're skipped.
+func (l lessThanPart) Seek() Scalar {
+	return BytesScalar{}
+}
+
+func (l lessThanPart) Condition(key Key, transform Transform) bool {
+	transformed := l.value
+	if transform != nil {
+		transformed = transform(l.value)
+	}
+
+	return bytes.Compare(key, transformed.Bytes()) < 0
+}
+
+func (l lessThanPart) excludesSeek() {}
+
 type prefixPart struct {
 	queryPath QueryPath
 	value     Scalar
@@ -241,6 +434,200 @@ func (p prefixPart) Condition(key Key, transform Transform) bool {
 	return bytes.HasPrefix(key, transformed.Bytes())
 }
 
+type suffixPart struct {
+	queryPath QueryPath
+	value     Scalar
+}
+
+func (s suffixPart) Equals(other QueryPathComparable) bool {
+	return s.queryPath.Equals(other.QueryPath())
+}
+
+func (s suffixPart) QueryPath() QueryPath {
+	return s.queryPath
+}
+
+// Seek returns an empty key, a suffix can occur anywhere in an index
+func (s suffixPart) Seek() Scalar {
+	return BytesScalar{}
+}
+
+func (s suffixPart) Condition(key Key, transform Transform) bool {
+	transformed := s.value
+	if transform != nil {
+		transformed = transform(s.value)
+	}
+
+	return bytes.HasSuffix(key, transformed.Bytes())
+}
+
+type regexPart struct {
+	queryPath QueryPath
+	pattern   *regexp.Regexp
+}
+
+func (r regexPart) Equals(other QueryPathComparable) bool {
+	return r.queryPath.Equals(other.QueryPath())
+}
+
+func (r regexPart) QueryPath() QueryPath {
+	return r.queryPath
+}
+
+// Seek returns an empty key, a match can occur anywhere in an index
+func (r regexPart) Seek() Scalar {
+	return StringScalar("")
+}
+
+func (r regexPart) Condition(key Key, _ Transform) bool {
+	return r.pattern.Match(key)
+}
+
+// checkScalar returns an error for non-string values, the pattern can't be applied to their binary form
+func (r regexPart) checkScalar(value Scalar) error {
+	if _, ok := value.(StringScalar); !ok {
+		return fmt.Errorf("%w: regex on %s can only be applied to strings, got %T", ErrInvalidValue, r.queryPath, value)
+	}
+	return nil
+}
+
+// multiSeeker is implemented by query parts that are resolved by multiple seeks in an index
+type multiSeeker interface {
+	seeks() []Scalar
+}
+
+type inPart struct {
+	queryPath QueryPath
+	// values are sorted in ascending order
+	values  []Scalar
+	members map[string]struct{}
+}
+
+func (i inPart) Equals(other QueryPathComparable) bool {
+	return i.queryPath.Equals(other.QueryPath())
+}
+
+func (i inPart) QueryPath() QueryPath {
+	return i.queryPath
+}
+
+// Seek returns the smallest value
+func (i inPart) Seek() Scalar {
+	return i.values[0]
+}
+
+func (i inPart) Condition(key Key, transform Transform) bool {
+	if transform == nil {
+		_, ok := i.members[string(key)]
+		return ok
+	}
+	for _, value := range i.values {
+		if bytes.Equal(key, transform(value).Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i inPart) seeks() []Scalar {
+	return i.values
+}
+
+type orPart struct {
+	parts []QueryPart
+}
+
+func (o orPart) Equals(other QueryPathComparable) bool {
+	return o.parts[0].Equals(other)
+}
+
+func (o orPart) QueryPath() QueryPath {
+	return o.parts[0].QueryPath()
+}
+
+// Seek returns the lowest seek of all parts
+func (o orPart) Seek() Scalar {
+	seeks := o.seeks()
+	lowest := seeks[0]
+	for _, seek := range seeks[1:] {
+		if bytes.Compare(seek.Bytes(), lowest.Bytes()) < 0 {
+			lowest = seek
+		}
+	}
+	return lowest
+}
+
+func (o orPart) Condition(key Key, transform Transform) bool {
+	for _, part := range o.parts {
+		if part.Condition(key, transform) {
+			return true
+		}
+	}
+	return false
+}
+
+// seeks returns the seek of every part, nested Or and In parts are flattened
+func (o orPart) seeks() []Scalar {
+	seeks := make([]Scalar, 0, len(o.parts))
+	for _, part := range o.parts {
+		if nested, ok := part.(multiSeeker); ok {
+			seeks = append(seeks, nested.seeks()...)
+		} else {
+			seeks = append(seeks, part.Seek())
+		}
+	}
+	return seeks
+}
+
+// seekTerm is a value to seek to in an index. Keys equal to an exclusive value don't match.
+type seekTerm struct {
+	value     Scalar
+	exclusive bool
+}
+
+// seekTerms returns the values to seek to for the query part, nested Or and In parts are flattened.
+// Exclusiveness is kept per value, so a GreaterThan within an Or skips the key it seeks to.
+func seekTerms(part QueryPart) []seekTerm {
+	switch p := part.(type) {
+	case orPart:
+		terms := make([]seekTerm, 0, len(p.parts))
+		for _, nested := range p.parts {
+			terms = append(terms, seekTerms(nested)...)
+		}
+		return terms
+	case multiSeeker:
+		values := p.seeks()
+		terms := make([]seekTerm, len(values))
+		for j, value := range values {
+			terms[j] = seekTerm{value: value}
+		}
+		return terms
+	}
+	_, exclusive := part.(exclusiveSeeker)
+	return []seekTerm{{value: part.Seek(), exclusive: exclusive}}
+}
+
+// indexable returns false if the part can't be found using the sorted index keys, like a suffix
+func (o orPart) indexable() bool {
+	for _, part := range o.parts {
+		if !isIndexable(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndexable returns false for query parts that can't be resolved using the sorted index keys
+func isIndexable(part QueryPart) bool {
+	switch p := part.(type) {
+	case suffixPart, regexPart:
+		return false
+	case orPart:
+		return p.indexable()
+	}
+	return true
+}
+
 type notNilPart struct {
 	queryPath QueryPath
 }
@@ -254,9 +641,53 @@ func (p notNilPart) QueryPath() QueryPath {
 }
 
 func (p notNilPart) Seek() Scalar {
-	return bytesScalar{}
+	return BytesScalar{}
 }
 
 func (p notNilPart) Condition(key Key, _ Transform) bool {
 	return len(key) > 0
 }
+
+// describeQuery returns a human-readable description of the query parts, it's used for logging
+func describeQuery(query Query) string {
+	descriptions := make([]string, len(query.parts))
+	for j, part := range query.parts {
+		descriptions[j] = describeQueryPart(part)
+	}
+	return strings.Join(descriptions, " and ")
+}
+
+// describeQueryPart returns a human-readable description of a query part, it's used to explain query plans
+func describeQueryPart(part QueryPart) string {
+	switch p := part.(type) {
+	case eqPart:
+		return fmt.Sprintf("%s == %v", p.queryPath, p.value.value())
+	case rangePart:
+		return fmt.Sprintf("%s in range [%v, %v]", p.queryPath, p.begin.value(), p.end.value())
+	case greaterThanPart:
+		return fmt.Sprintf("%s > %v", p.queryPath, p.value.value())
+	case lessThanPart:
+		return fmt.Sprintf("%s < %v", p.queryPath, p.value.value())
+	case prefixPart:
+		return fmt.Sprintf("%s starts with %v", p.queryPath, p.value.value())
+	case suffixPart:
+		return fmt.Sprintf("%s ends with %v", p.queryPath, p.value.value())
+	case regexPart:
+		return fmt.Sprintf("%s matches /%s/", p.queryPath, p.pattern)
+	case notNilPart:
+		return fmt.Sprintf("%s is not nil", p.queryPath)
+	case inPart:
+		values := make([]string, len(p.values))
+		for i, value := range p.values {
+			values[i] = fmt.Sprintf("%v", value.value())
+		}
+		return fmt.Sprintf("%s in [%s]", p.queryPath, strings.Join(values, ", "))
+	case orPart:
+		parts := make([]string, len(p.parts))
+		for i, nested := range p.parts {
+			parts[i] = describeQueryPart(nested)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")"
+	}
+	return fmt.Sprintf("%T on %v", part, part.QueryPath())
+}
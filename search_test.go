@@ -20,6 +20,7 @@
 package leia
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -110,6 +111,43 @@ func TestRange_Condition(t *testing.T) {
 	})
 }
 
+func TestGreaterThan_Condition(t *testing.T) {
+	qp := GreaterThan(testJsonPath, MustParseScalar("b"))
+
+	t.Run("ok - seek", func(t *testing.T) {
+		assert.Equal(t, "b", qp.Seek().value())
+	})
+
+	t.Run("ok - condition", func(t *testing.T) {
+		assert.False(t, qp.Condition(Key("a"), nil))
+		assert.False(t, qp.Condition(Key("b"), nil))
+		assert.True(t, qp.Condition(Key("ba"), nil))
+		assert.True(t, qp.Condition(Key("c"), nil))
+	})
+
+	t.Run("ok - with transform", func(t *testing.T) {
+		assert.True(t, GreaterThan(testJsonPath, MustParseScalar("A")).Condition(Key("b"), ToLower))
+	})
+}
+
+func TestLessThan_Condition(t *testing.T) {
+	qp := LessThan(testJsonPath, MustParseScalar("b"))
+
+	t.Run("ok - seek", func(t *testing.T) {
+		assert.Equal(t, []byte{}, qp.Seek().Bytes())
+	})
+
+	t.Run("ok - condition", func(t *testing.T) {
+		assert.True(t, qp.Condition(Key("a"), nil))
+		assert.False(t, qp.Condition(Key("b"), nil))
+		assert.False(t, qp.Condition(Key("ba"), nil))
+	})
+
+	t.Run("ok - with transform", func(t *testing.T) {
+		assert.True(t, LessThan(testJsonPath, MustParseScalar("B")).Condition(Key("a"), ToLower))
+	})
+}
+
 func TestPrefixPart_Condition(t *testing.T) {
 	qp := Prefix(testJsonPath, testAsScalar)
 
@@ -158,6 +196,54 @@ func TestPrefixPart_Equals(t *testing.T) {
 	})
 }
 
+func TestSuffixPart_Condition(t *testing.T) {
+	qp := Suffix(testJsonPath, testAsScalar)
+
+	t.Run("ok - seek", func(t *testing.T) {
+		s := qp.Seek()
+
+		assert.Equal(t, []byte{}, s.value())
+	})
+
+	t.Run("ok - condition true", func(t *testing.T) {
+		c := qp.Condition(Key("something test"), nil)
+
+		assert.True(t, c)
+	})
+
+	t.Run("ok - condition true with transform", func(t *testing.T) {
+		qp := Suffix(testJsonPath, MustParseScalar("TEST"))
+
+		c := qp.Condition(Key("something test"), ToLower)
+
+		assert.True(t, c)
+	})
+
+	t.Run("ok - condition false", func(t *testing.T) {
+		c := qp.Condition(Key("test is not"), nil)
+
+		assert.False(t, c)
+	})
+
+	t.Run("ok - key too short", func(t *testing.T) {
+		c := qp.Condition(Key("st"), nil)
+
+		assert.False(t, c)
+	})
+}
+
+func TestSuffixPart_Equals(t *testing.T) {
+	qp := Suffix(testJsonPath, testAsScalar)
+
+	t.Run("true", func(t *testing.T) {
+		assert.True(t, qp.Equals(qp))
+	})
+
+	t.Run("false", func(t *testing.T) {
+		assert.False(t, qp.Equals(Suffix(NewJSONPath("a"), MustParseScalar("a"))))
+	})
+}
+
 func TestRangePart_Equals(t *testing.T) {
 	qp := Range(testJsonPath, MustParseScalar("a"), MustParseScalar("b"))
 
@@ -188,10 +274,34 @@ func TestTermPath_Equals(t *testing.T) {
 	})
 }
 
+func TestIRIPathWildcard(t *testing.T) {
+	t.Run("ok - equals same wildcard path", func(t *testing.T) {
+		assert.True(t, IRIPathWildcard([]string{"a"}, []string{"b"}).Equals(IRIPathWildcard([]string{"a"}, []string{"b"})))
+	})
+
+	t.Run("ok - differs from path without wildcard", func(t *testing.T) {
+		assert.False(t, IRIPathWildcard([]string{"a"}, []string{"b"}).Equals(NewIRIPath("a", "b")))
+	})
+}
+
 func TestJSONPath_Equals(t *testing.T) {
 	assert.False(t, NewIRIPath().Equals(NewJSONPath(".")))
 }
 
+func TestJSONPath_String(t *testing.T) {
+	assert.Equal(t, "path.#.part", NewJSONPath("path.#.part").String())
+}
+
+func TestIRIPath_String(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		assert.Equal(t, "http://example.com/parent > http://example.com/child", NewIRIPath("http://example.com/parent", "http://example.com/child").String())
+	})
+
+	t.Run("ok - empty", func(t *testing.T) {
+		assert.Equal(t, "", NewIRIPath().String())
+	})
+}
+
 func TestNotNilPart_Seek(t *testing.T) {
 	assert.Equal(t, []byte{}, NotNil(testJsonPath).Seek().value())
 }
@@ -201,6 +311,133 @@ func TestNotNilPart_Condition(t *testing.T) {
 	assert.False(t, NotNil(testJsonPath).Condition([]byte{}, nil))
 }
 
+func TestRegex(t *testing.T) {
+	part := Regex(testJsonPath, regexp.MustCompile(`^did:nuts:[a-z0-9]+$`))
+
+	t.Run("ok - condition", func(t *testing.T) {
+		assert.True(t, part.Condition([]byte("did:nuts:abc123"), nil))
+		assert.False(t, part.Condition([]byte("did:nuts:ABC"), nil))
+	})
+
+	t.Run("ok - seek from the start", func(t *testing.T) {
+		assert.Equal(t, []byte{}, part.Seek().Bytes())
+	})
+
+	t.Run("ok - not indexable", func(t *testing.T) {
+		assert.False(t, isIndexable(part))
+	})
+
+	t.Run("error - non-string value", func(t *testing.T) {
+		err := part.(regexPart).checkScalar(Float64Scalar(1))
+
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+}
+
+func TestOr(t *testing.T) {
+	a := Eq(testJsonPath, MustParseScalar("a"))
+	c := Eq(testJsonPath, MustParseScalar("c"))
+
+	t.Run("ok - condition matches any part", func(t *testing.T) {
+		or := Or(c, a)
+
+		assert.True(t, or.Condition([]byte("a"), nil))
+		assert.True(t, or.Condition([]byte("c"), nil))
+		assert.False(t, or.Condition([]byte("b"), nil))
+	})
+
+	t.Run("ok - seek returns lowest seek", func(t *testing.T) {
+		assert.Equal(t, []byte("a"), Or(c, a).Seek().Bytes())
+	})
+
+	t.Run("ok - nested parts are flattened", func(t *testing.T) {
+		or := Or(a, Or(c, Prefix(testJsonPath, MustParseScalar("x")))).(orPart)
+
+		assert.Len(t, or.seeks(), 3)
+	})
+
+	t.Run("ok - equals path of parts", func(t *testing.T) {
+		assert.True(t, Or(a, c).Equals(NewFieldIndexer(testJsonPath)))
+		assert.False(t, Or(a, c).Equals(NewFieldIndexer(NewJSONPath("other"))))
+	})
+
+	t.Run("ok - not indexable with a suffix", func(t *testing.T) {
+		assert.True(t, isIndexable(Or(a, c)))
+		assert.False(t, isIndexable(Or(a, Suffix(testJsonPath, MustParseScalar("c")))))
+	})
+
+	t.Run("error - different paths", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Or(a, Eq(NewJSONPath("other"), MustParseScalar("c")))
+		})
+	})
+
+	t.Run("error - no parts", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Or()
+		})
+	})
+}
+
+func TestIn(t *testing.T) {
+	b := MustParseScalar("b")
+	a := MustParseScalar("a")
+	c := MustParseScalar("c")
+
+	t.Run("ok - condition checks membership", func(t *testing.T) {
+		in := In(testJsonPath, c, a)
+
+		assert.True(t, in.Condition([]byte("a"), nil))
+		assert.True(t, in.Condition([]byte("c"), nil))
+		assert.False(t, in.Condition([]byte("b"), nil))
+	})
+
+	t.Run("ok - condition with transform", func(t *testing.T) {
+		in := In(testJsonPath, MustParseScalar("A"))
+
+		assert.True(t, in.Condition([]byte("a"), ToLower))
+	})
+
+	t.Run("ok - seek returns smallest value", func(t *testing.T) {
+		assert.Equal(t, []byte("a"), In(testJsonPath, c, a, b).Seek().Bytes())
+	})
+
+	t.Run("ok - values are sorted and deduplicated", func(t *testing.T) {
+		in := In(testJsonPath, c, a, b, a).(inPart)
+
+		assert.Equal(t, []Scalar{a, b, c}, in.seeks())
+	})
+
+	t.Run("ok - flattened in Or", func(t *testing.T) {
+		or := Or(Eq(testJsonPath, a), In(testJsonPath, b, c)).(orPart)
+
+		assert.Len(t, or.seeks(), 3)
+	})
+
+	t.Run("error - no values", func(t *testing.T) {
+		assert.Panics(t, func() {
+			In(testJsonPath)
+		})
+	})
+}
+
+func TestDescribeQueryPart(t *testing.T) {
+	a := MustParseScalar("a")
+	b := MustParseScalar("b")
+
+	assert.Equal(t, "test == a", describeQueryPart(Eq(testJsonPath, a)))
+	assert.Equal(t, "test in range [a, b]", describeQueryPart(Range(testJsonPath, a, b)))
+	assert.Equal(t, "test > a", describeQueryPart(GreaterThan(testJsonPath, a)))
+	assert.Equal(t, "test < a", describeQueryPart(LessThan(testJsonPath, a)))
+	assert.Equal(t, "test starts with a", describeQueryPart(Prefix(testJsonPath, a)))
+	assert.Equal(t, "test ends with a", describeQueryPart(Suffix(testJsonPath, a)))
+	assert.Equal(t, "test matches /^a$/", describeQueryPart(Regex(testJsonPath, regexp.MustCompile("^a$"))))
+	assert.Equal(t, "test is not nil", describeQueryPart(NotNil(testJsonPath)))
+	assert.Equal(t, "test in [a, b]", describeQueryPart(In(testJsonPath, b, a)))
+	assert.Equal(t, "(test == a OR test starts with b)", describeQueryPart(Or(Eq(testJsonPath, a), Prefix(testJsonPath, b))))
+	assert.Equal(t, "leia.customPart on test", describeQueryPart(customPart{queryPath: testJsonPath}))
+}
+
 func TestNotNilPart_Equals(t *testing.T) {
 	qp := NotNil(testJsonPath)
 
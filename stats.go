@@ -0,0 +1,69 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import "sync/atomic"
+
+// CollectionStats is a snapshot of the read statistics of a collection.
+// The counters are kept since the collection was created or since the last call to ResetStats.
+type CollectionStats struct {
+	// FindCount is the number of calls to Find
+	FindCount int64
+	// GetCount is the number of calls to Get
+	GetCount int64
+	// IterateCount is the number of calls to Iterate
+	IterateCount int64
+	// FullTableScanCount is the number of queries that were executed without an index
+	FullTableScanCount int64
+	// IndexScanCount is the number of queries that were executed using an index
+	IndexScanCount int64
+	// DocumentsFetched is the number of documents read from the document bucket
+	DocumentsFetched int64
+}
+
+// collectionStats holds the counters of a collection
+type collectionStats struct {
+	find             atomic.Int64
+	get              atomic.Int64
+	iterate          atomic.Int64
+	fullTableScan    atomic.Int64
+	indexScan        atomic.Int64
+	documentsFetched atomic.Int64
+}
+
+func (s *collectionStats) snapshot() CollectionStats {
+	return CollectionStats{
+		FindCount:          s.find.Load(),
+		GetCount:           s.get.Load(),
+		IterateCount:       s.iterate.Load(),
+		FullTableScanCount: s.fullTableScan.Load(),
+		IndexScanCount:     s.indexScan.Load(),
+		DocumentsFetched:   s.documentsFetched.Load(),
+	}
+}
+
+func (s *collectionStats) reset() {
+	s.find.Store(0)
+	s.get.Store(0)
+	s.iterate.Store(0)
+	s.fullTableScan.Store(0)
+	s.indexScan.Store(0)
+	s.documentsFetched.Store(0)
+}
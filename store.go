@@ -20,8 +20,17 @@
 package leia
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/piprate/json-gold/ld"
 	"go.etcd.io/bbolt"
@@ -37,20 +46,80 @@ const (
 	JSONLDCollection
 )
 
+// String returns the name of the collection type, which is stored in the collection metadata.
+func (t CollectionType) String() string {
+	switch t {
+	case JSONCollection:
+		return "JSON"
+	case JSONLDCollection:
+		return "JSON-LD"
+	default:
+		return fmt.Sprintf("CollectionType(%d)", int(t))
+	}
+}
+
 // Store is the main interface for storing/finding documents
 type Store interface {
-	// Collection creates or returns a Collection of the specified type.
+	// Collection creates or returns a Collection of the specified type, like OpenCollection.
+	// It panics if the collection exists with a different type, also when it was created by an earlier process.
+	//
+	// Deprecated: use OpenCollection, which returns an error instead.
+	Collection(collectionType CollectionType, name string, options ...CollectionOption) Collection
+	// OpenCollection creates or returns a Collection of the specified type.
 	// On the db level it's a bucket for the documents and 1 bucket per index.
-	Collection(collectionType CollectionType, name string) Collection
+	// The options are only applied when the collection is created.
+	// The type is stored when the first document is added. ErrCollectionTypeMismatch is returned if the collection exists with a different type,
+	// also when it was created by an earlier process.
+	OpenCollection(collectionType CollectionType, name string, options ...CollectionOption) (Collection, error)
+	// JSONCollection creates or returns a JSON Collection. It's a shorthand for Collection(JSONCollection, name), so it panics on a type mismatch.
+	//
+	// Deprecated: use OpenCollection(JSONCollection, name), which returns an error instead.
+	JSONCollection(name string, options ...CollectionOption) Collection
+	// JSONLDCollection creates or returns a JSON-LD Collection. It's a shorthand for Collection(JSONLDCollection, name), so it panics on a type mismatch.
+	//
+	// Deprecated: use OpenCollection(JSONLDCollection, name), which returns an error instead.
+	JSONLDCollection(name string, options ...CollectionOption) Collection
+	// Collections returns the names of the collections stored in the bbolt file, in lexicographical order.
+	// A collection is stored when a document or an index is added to it, collections that are only created in memory aren't returned.
+	Collections() ([]string, error)
+	// HasCollection returns true if a collection with the given name is stored in the bbolt file, see Collections.
+	HasCollection(name string) bool
+	// CopyCollection copies all documents of the source collection to the destination collection, which is created if needed.
+	// Documents are indexed by the indices of the destination collection, so add those before copying.
+	// The copy is done in batches, each batch uses its own transaction. It returns the number of copied documents.
+	CopyCollection(ctx context.Context, srcName string, dstName string, dstType CollectionType) (int, error)
+	// Vacuum compacts the bbolt file by copying all buckets to a new file, which then replaces the original file.
+	// It returns the number of bytes the file has shrunk. Running transactions are completed first, other operations wait until the vacuum is done.
+	// When the file can't be reopened, the store is unusable and all operations return ErrStoreUnusable.
+	Vacuum() (int64, error)
 	// Close the bbolt DB
 	Close() error
 }
 
 // Store holds a reference to the bbolt data file and all collections.
 type store struct {
-	db             *bbolt.DB
-	collections    map[string]*collection
-	documentLoader ld.DocumentLoader
+	db *boltDB
+	// collectionsLock protects collections
+	collectionsLock sync.Mutex
+	collections     map[string]*collection
+	documentLoader  ld.DocumentLoader
+	// loaderCacheSize and loaderCacheTTL wrap the document loader in a cache when loaderCacheSize is set
+	loaderCacheSize     int
+	loaderCacheTTL      time.Duration
+	strictBackfill      bool
+	autoRebuild         bool
+	missingPlaceholders bool
+	maxBatchSize        int
+	maxDocumentSize     int
+	refMake             ReferenceFunc
+	logger              *slog.Logger
+	// fileMode is used for the bbolt file, dirMode for the directory that contains it
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	// initialSize is the minimal size of the bbolt file in bytes
+	initialSize int64
+	// tempDir is removed when the store is closed, it's only set for a store created by NewMemStore
+	tempDir string
 	// options is used during configuration
 	options bbolt.Options
 }
@@ -73,35 +142,222 @@ func WithDocumentLoader(documentLoader ld.DocumentLoader) StoreOption {
 
 }
 
+// WithCachingDocumentLoader is a store option which caches the JSON-LD contexts loaded by the document loader, keyed by URL.
+// At most maxEntries documents are kept, the least recently used is evicted first. An entry is reloaded after ttl.
+// It also wraps a loader given by WithDocumentLoader, but not one set with Collection.SetDocumentLoader. Values < 1 are ignored.
+func WithCachingDocumentLoader(maxEntries int, ttl time.Duration) StoreOption {
+	return func(store *store) {
+		if maxEntries > 0 {
+			store.loaderCacheSize = maxEntries
+			store.loaderCacheTTL = ttl
+		}
+	}
+}
+
+// WithLogger is a store option which logs what the store and its collections do to the given logger.
+// Index lookups and seeks are logged at debug level, index changes and Store.Vacuum at info level,
+// full table scans at warn level and failing bbolt transactions at error level.
+func WithLogger(logger *slog.Logger) StoreOption {
+	return func(store *store) {
+		if logger != nil {
+			store.logger = logger
+		}
+	}
+}
+
+// WithReadOnly is a store option which opens the bbolt file in read-only mode, the file must exist.
+// Writes return ErrReadOnly. Collection.AddIndex only accepts indices that are already stored, so they can be used by queries.
+// Multiple processes can open the same file in read-only mode.
+func WithReadOnly() StoreOption {
+	return func(store *store) {
+		store.options.ReadOnly = true
+	}
+}
+
+// WithStrictBackfill is a store option which causes Collection.AddIndex to fail and roll back the new index
+// when an existing document can't be indexed. By default, the backfill completes and the failures are reported in a BackfillError.
+func WithStrictBackfill() StoreOption {
+	return func(store *store) {
+		store.strictBackfill = true
+	}
+}
+
+// WithAutoRebuildOnMismatch is a store option which causes Collection.AddIndex to rebuild a stored index when its configuration has changed.
+// By default, ErrIndexSchemaMismatch is returned.
+func WithAutoRebuildOnMismatch() StoreOption {
+	return func(store *store) {
+		store.autoRebuild = true
+	}
+}
+
+// WithHashFunc is a store option which creates document references using the given hash function instead of SHA-1, e.g. sha256.New.
+// Existing documents are stored under their old reference, so it shouldn't be changed for an existing store.
+func WithHashFunc(fn func() hash.Hash) StoreOption {
+	return func(store *store) {
+		store.refMake = hashReferenceCreator(fn)
+	}
+}
+
+// WithReferenceFunc is a store option which creates document references using the given ReferenceFunc instead of SHA-1,
+// e.g. SHA256ReferenceCreator. Adding documents to a collection that contains documents with references of another ReferenceFunc
+// returns ErrReferenceFuncMismatch.
+func WithReferenceFunc(fn ReferenceFunc) StoreOption {
+	return func(store *store) {
+		store.refMake = fn
+	}
+}
+
+// WithMaxBatchSize is a store option which causes Collection.Add to add at most n documents per transaction,
+// so a large set of documents doesn't hold the write lock for a long time.
+// If a transaction fails, the documents of earlier transactions remain added and a BatchError is returned.
+func WithMaxBatchSize(n int) StoreOption {
+	return func(store *store) {
+		store.maxBatchSize = n
+	}
+}
+
+// WithDocumentSizeLimit is a store option which causes documents larger than maxBytes to be rejected with a DocumentSizeError.
+// The size of the document before compression is checked. The transaction is rolled back, so none of the documents are added.
+func WithDocumentSizeLimit(maxBytes int) StoreOption {
+	return func(store *store) {
+		store.maxDocumentSize = maxBytes
+	}
+}
+
+// WithMissingReferencePlaceholders is a store option which causes Collection.FindByReference to return a nil Document for every missing reference.
+// By default, missing documents are omitted from the result.
+func WithMissingReferencePlaceholders() StoreOption {
+	return func(store *store) {
+		store.missingPlaceholders = true
+	}
+}
+
+// WithFileMode overrides the default file mode (0600) of the bbolt file.
+// The directory containing the file is created with the same mode, including execute permission where read permission is given.
+func WithFileMode(mode os.FileMode) StoreOption {
+	return func(store *store) {
+		store.fileMode = mode
+		store.dirMode = mode | (mode&0444)>>2
+	}
+}
+
+// WithInitialSize pre-allocates the bbolt file to the given number of bytes.
+// This prevents fragmentation when a new store grows through many small writes.
+// A file that is already larger is left untouched.
+func WithInitialSize(bytes int64) StoreOption {
+	return func(store *store) {
+		store.initialSize = bytes
+	}
+}
+
+// WithFreelistType sets the type of the bbolt freelist, either bbolt.FreelistArrayType (default) or bbolt.FreelistMapType.
+// The map type is faster for large, fragmented databases.
+func WithFreelistType(t bbolt.FreelistType) StoreOption {
+	return func(store *store) {
+		store.options.FreelistType = t
+	}
+}
+
+// WithInitialMmapSize sets the initial size of the bbolt memory map in bytes.
+// Read transactions don't block write transactions when the database fits within the map.
+func WithInitialMmapSize(size int) StoreOption {
+	return func(store *store) {
+		store.options.InitialMmapSize = size
+	}
+}
+
 // NewStore creates a new store.
 // the noSync option disables flushing to disk, ideal for testing and bulk loading
 func NewStore(dbFile string, options ...StoreOption) (Store, error) {
-	err := os.MkdirAll(filepath.Dir(dbFile), os.ModePerm)
-	if err != nil {
-		return nil, err
-	}
-
 	// store with defaults
 	st := &store{
 		options:        *bbolt.DefaultOptions,
 		collections:    map[string]*collection{},
 		documentLoader: ld.NewDefaultDocumentLoader(nil),
+		refMake:        defaultReferenceCreator,
+		logger:         discardLogger,
+		fileMode:       boltDBFileMode,
+		dirMode:        os.ModePerm,
 	}
 
 	// apply options
 	for _, option := range options {
 		option(st)
 	}
+	if st.loaderCacheSize > 0 {
+		st.documentLoader = newCachingDocumentLoader(st.documentLoader, st.loaderCacheSize, st.loaderCacheTTL)
+	}
+	if st.options.FreelistType != bbolt.FreelistArrayType && st.options.FreelistType != bbolt.FreelistMapType {
+		return nil, fmt.Errorf("unknown freelist type: %s", st.options.FreelistType)
+	}
 
-	st.db, err = bbolt.Open(dbFile, boltDBFileMode, &st.options)
+	err := os.MkdirAll(filepath.Dir(dbFile), st.dirMode)
 	if err != nil {
 		return nil, err
 	}
 
+	db, err := bbolt.Open(dbFile, st.fileMode, &st.options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the file must be initialized by bbolt before it's pre-allocated
+	if st.initialSize > 0 && !st.options.ReadOnly {
+		if err = preallocate(dbFile, st.initialSize); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	st.db = newBoltDB(db)
+
 	return st, nil
 }
 
-func (s *store) Collection(collectionType CollectionType, name string) Collection {
+// NewMemStore creates a throw-away Store, e.g. to test code that uses leia.
+// bbolt requires a file, so the data is stored in a temporary directory without syncing to disk. The directory is removed on Close.
+func NewMemStore(options ...StoreOption) (Store, error) {
+	dir, err := os.MkdirTemp("", "leia-")
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewStore(filepath.Join(dir, "leia.db"), append([]StoreOption{WithoutSync()}, options...)...)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	s.(*store).tempDir = dir
+	return s, nil
+}
+
+// preallocate grows the file to the given size if it's smaller
+func preallocate(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() >= size {
+		return nil
+	}
+	return fallocate(f, size)
+}
+
+func (s *store) Collection(collectionType CollectionType, name string, options ...CollectionOption) Collection {
+	c, err := s.OpenCollection(collectionType, name, options...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (s *store) OpenCollection(collectionType CollectionType, name string, options ...CollectionOption) (Collection, error) {
+	s.collectionsLock.Lock()
+	defer s.collectionsLock.Unlock()
 	c, ok := s.collections[name]
 	if !ok {
 		var vCollector valueCollector
@@ -113,24 +369,242 @@ func (s *store) Collection(collectionType CollectionType, name string) Collectio
 		default:
 			panic("unknown collection type")
 		}
+		stored, err := s.storedCollectionType(name)
+		if err != nil {
+			return nil, err
+		}
+		if stored != "" && stored != collectionType.String() {
+			return nil, fmt.Errorf("%w: %s is a %s collection", ErrCollectionTypeMismatch, name, stored)
+		}
 		c = &collection{
-			name:           name,
-			collectionType: collectionType,
-			db:             s.db,
-			documentLoader: s.documentLoader,
-			refMake:        defaultReferenceCreator,
-			valueCollector: vCollector,
+			name:                name,
+			collectionType:      collectionType,
+			documentBucketName:  documentCollection,
+			db:                  s.db,
+			documentLoader:      s.documentLoader,
+			refMake:             s.refMake,
+			valueCollector:      vCollector,
+			strictBackfill:      s.strictBackfill,
+			autoRebuild:         s.autoRebuild,
+			compression:         NoOpCodec{},
+			missingPlaceholders: s.missingPlaceholders,
+			maxBatchSize:        s.maxBatchSize,
+			maxDocumentSize:     s.maxDocumentSize,
+			logger:              s.logger.With("collection", name),
+			readOnly:            s.options.ReadOnly,
+		}
+		for _, option := range options {
+			option(c)
+		}
+		if !strings.HasPrefix(c.documentBucketName, "_") {
+			panic("document bucket name must start with an underscore")
+		}
+		if c.documentBucketName == collectionMetadataBucket {
+			panic("document bucket name is reserved")
 		}
 		s.collections[name] = c
 	} else if c.collectionType != collectionType {
-		panic("collection already exists with different type")
+		return nil, fmt.Errorf("%w: %s is a %s collection", ErrCollectionTypeMismatch, name, c.collectionType)
 	}
 
-	return c
+	return c, nil
+}
+
+// storedCollectionType returns the type stored in the metadata of the collection, or an empty string if it isn't stored.
+func (s *store) storedCollectionType(name string) (string, error) {
+	var stored string
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		if metaBucket := bucket.Bucket([]byte(collectionMetadataBucket)); metaBucket != nil {
+			stored = string(metaBucket.Get([]byte(collectionTypeMetadataKey)))
+		}
+		return nil
+	})
+	return stored, err
+}
+
+func (s *store) JSONCollection(name string, options ...CollectionOption) Collection {
+	return s.Collection(JSONCollection, name, options...)
+}
+
+func (s *store) JSONLDCollection(name string, options ...CollectionOption) Collection {
+	return s.Collection(JSONLDCollection, name, options...)
+}
+
+func (s *store) Collections() ([]string, error) {
+	names := make([]string, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		// every top-level bucket is a collection, the store doesn't have buckets of its own
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			names = append(names, string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (s *store) HasCollection(name string) bool {
+	var exists bool
+	_ = s.db.View(func(tx *bbolt.Tx) error {
+		exists = tx.Bucket([]byte(name)) != nil
+		return nil
+	})
+	return exists
 }
+
+// copyBatchSize is the number of documents copied per transaction by CopyCollection and Collection.CopyTo
+const copyBatchSize = 1000
+
+func (s *store) CopyCollection(ctx context.Context, srcName string, dstName string, dstType CollectionType) (int, error) {
+	if srcName == dstName {
+		return 0, errors.New("source and destination collection must differ")
+	}
+	dst, err := s.OpenCollection(dstType, dstName)
+	if err != nil {
+		return 0, err
+	}
+	srcBucketName := []byte(documentCollection)
+	s.collectionsLock.Lock()
+	if src, ok := s.collections[srcName]; ok {
+		srcBucketName = src.documentCollectionByteRef()
+	}
+	s.collectionsLock.Unlock()
+
+	return copyDocuments(ctx, s.db, srcName, srcBucketName, dst)
+}
+
+// copyDocuments adds the documents of the given collection bucket to the destination collection, in batches of copyBatchSize.
+// It returns the number of copied documents.
+func copyDocuments(ctx context.Context, db *boltDB, srcName string, srcBucketName []byte, dst Collection) (int, error) {
+	count := 0
+	var lastRef []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		batch := make([]Document, 0, copyBatchSize)
+		err := db.View(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket([]byte(srcName))
+			if bucket == nil {
+				return nil
+			}
+			docBucket := bucket.Bucket(srcBucketName)
+			if docBucket == nil {
+				return nil
+			}
+			cursor := docBucket.Cursor()
+			ref, doc := cursor.First()
+			if lastRef != nil {
+				// continue after the last copied document
+				ref, doc = cursor.Seek(lastRef)
+				if bytes.Equal(ref, lastRef) {
+					ref, doc = cursor.Next()
+				}
+			}
+			for ; ref != nil && len(batch) < copyBatchSize; ref, doc = cursor.Next() {
+				decoded, err := decodeDocument(doc)
+				if err != nil {
+					return err
+				}
+				// copy the data, it's only valid during the transaction
+				batch = append(batch, append(Document{}, decoded...))
+				lastRef = append([]byte{}, ref...)
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		if len(batch) == 0 {
+			return count, nil
+		}
+		if err = dst.Add(ctx, batch); err != nil {
+			return count, err
+		}
+		count += len(batch)
+	}
+}
+
+// vacuumTxMaxSize is the number of bytes copied per transaction by Store.Vacuum
+const vacuumTxMaxSize = 64 * 1024 * 1024
+
+func (s *store) Vacuum() (int64, error) {
+	recovered, err := s.vacuum()
+	if err != nil {
+		s.logger.Error("vacuum failed", "error", err)
+		return 0, err
+	}
+	s.logger.Info("vacuum completed", "recovered_bytes", recovered)
+	return recovered, nil
+}
+
+func (s *store) vacuum() (int64, error) {
+	if s.options.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	var recovered int64
+	err := s.db.replace(func(current *bbolt.DB) (*bbolt.DB, error) {
+		path := current.Path()
+		before, err := os.Stat(path)
+		if err != nil {
+			return current, err
+		}
+
+		tmpPath := path + ".vacuum"
+		if err = os.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return current, err
+		}
+		compacted, err := bbolt.Open(tmpPath, s.fileMode, &s.options)
+		if err != nil {
+			return current, err
+		}
+		err = bbolt.Compact(compacted, current, vacuumTxMaxSize)
+		if closeErr := compacted.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(tmpPath)
+			return current, err
+		}
+
+		if err = current.Close(); err != nil {
+			_ = os.Remove(tmpPath)
+			return nil, err
+		}
+		renameErr := os.Rename(tmpPath, path)
+		// the original file is reopened when it couldn't be replaced
+		db, err := bbolt.Open(path, s.fileMode, &s.options)
+		if err != nil {
+			return nil, err
+		}
+		if renameErr != nil {
+			_ = os.Remove(tmpPath)
+			return db, renameErr
+		}
+
+		after, err := os.Stat(path)
+		if err != nil {
+			return db, err
+		}
+		recovered = before.Size() - after.Size()
+		return db, nil
+	})
+	return recovered, err
+}
+
 func (s *store) Close() error {
-	if s.db != nil {
-		return s.db.Close()
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	if s.tempDir != "" {
+		return os.RemoveAll(s.tempDir)
 	}
 	return nil
 }
@@ -20,11 +20,21 @@
 package leia
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/piprate/json-gold/ld"
 	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
 )
 
 func TestNewStore(t *testing.T) {
@@ -39,6 +49,44 @@ func TestNewStore(t *testing.T) {
 		assert.NotNil(t, s)
 	})
 
+	t.Run("ok - with file mode", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "sub", "test.db")
+		s, err := NewStore(f, WithoutSync(), WithFileMode(0640))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		fileInfo, _ := os.Stat(f)
+		assert.Equal(t, os.FileMode(0640), fileInfo.Mode().Perm())
+		dirInfo, _ := os.Stat(filepath.Dir(f))
+		assert.Equal(t, os.FileMode(0750), dirInfo.Mode().Perm())
+	})
+
+	t.Run("ok - with initial size", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, err := NewStore(f, WithoutSync(), WithInitialSize(1<<20))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		_ = s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})
+		_ = s.Close()
+
+		fileInfo, _ := os.Stat(f)
+		assert.GreaterOrEqual(t, fileInfo.Size(), int64(1<<20))
+
+		// reopening keeps the data
+		s, err = NewStore(f, WithoutSync(), WithInitialSize(1024))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+		count, _ := s.JSONCollection("test").DocumentCount()
+		assert.Equal(t, 1, count)
+	})
+
 	t.Run("error", func(t *testing.T) {
 		_, err := NewStore("store_test.go", WithoutSync())
 
@@ -46,6 +94,45 @@ func TestNewStore(t *testing.T) {
 	})
 }
 
+func TestNewMemStore(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s, err := NewMemStore()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+		c := s.JSONCollection("test")
+
+		err = c.Add(context.TODO(), []Document{exampleDoc})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		doc, _, err := c.Get(c.Reference(exampleDoc))
+		assert.NoError(t, err)
+		assert.Equal(t, Document(exampleDoc), doc)
+	})
+
+	t.Run("ok - options are applied", func(t *testing.T) {
+		s, _ := NewMemStore(WithStrictBackfill())
+		defer s.Close()
+
+		assert.True(t, s.(*store).strictBackfill)
+		assert.True(t, s.(*store).db.db.NoSync)
+	})
+
+	t.Run("ok - temporary directory is removed on close", func(t *testing.T) {
+		s, _ := NewMemStore()
+		dir := s.(*store).tempDir
+
+		err := s.Close()
+
+		assert.NoError(t, err)
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
 func TestStore_JSONCollection(t *testing.T) {
 	f := filepath.Join(testDirectory(t), "test.db")
 	s, _ := NewStore(f, WithoutSync())
@@ -60,6 +147,101 @@ func TestStore_JSONCollection(t *testing.T) {
 	assert.NotNil(t, c.(*collection).refMake)
 	assert.NotNil(t, c.(*collection).name)
 	assert.NotNil(t, c.(*collection).valueCollector)
+
+	t.Run("shorthand returns the same collection", func(t *testing.T) {
+		assert.Same(t, c, s.JSONCollection("test"))
+	})
+}
+
+func TestStore_Collections(t *testing.T) {
+	t.Run("ok - read from the file", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		_ = s.JSONCollection("b").Add(context.TODO(), []Document{exampleDoc})
+		c := s.JSONCollection("a")
+		_ = c.AddIndex(c.NewIndex("index", NewFieldIndexer(NewJSONPath("id"))))
+		s.JSONCollection("in memory")
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync())
+		defer s.Close()
+
+		names, err := s.Collections()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"a", "b"}, names)
+		assert.True(t, s.HasCollection("a"))
+		assert.False(t, s.HasCollection("in memory"))
+	})
+
+	t.Run("ok - empty store", func(t *testing.T) {
+		s, _ := NewMemStore()
+		defer s.Close()
+
+		names, err := s.Collections()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Empty(t, names)
+		assert.False(t, s.HasCollection("test"))
+	})
+}
+
+func TestStore_OpenCollection(t *testing.T) {
+	t.Run("ok - type is kept after reopening", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		_ = s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync())
+		defer s.Close()
+
+		c, err := s.OpenCollection(JSONCollection, "test")
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, JSONCollection, c.(*collection).collectionType)
+	})
+
+	t.Run("error - type mismatch after reopening", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		_ = s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync())
+		defer s.Close()
+
+		_, err := s.OpenCollection(JSONLDCollection, "test")
+
+		assert.ErrorIs(t, err, ErrCollectionTypeMismatch)
+		assert.EqualError(t, err, "collection exists with a different type: test is a JSON collection")
+		assert.Panics(t, func() {
+			s.JSONLDCollection("test")
+		})
+	})
+
+	t.Run("error - type mismatch in memory", func(t *testing.T) {
+		s, _ := NewMemStore()
+		defer s.Close()
+		s.JSONCollection("test")
+
+		_, err := s.OpenCollection(JSONLDCollection, "test")
+
+		assert.ErrorIs(t, err, ErrCollectionTypeMismatch)
+	})
+
+	t.Run("error - write with another type", func(t *testing.T) {
+		_, c := testCollection(t)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		c.collectionType = JSONLDCollection
+
+		err := c.Add(context.TODO(), []Document{Document(jsonExample2)})
+
+		assert.ErrorIs(t, err, ErrCollectionTypeMismatch)
+	})
 }
 
 func TestStore_JSONLDCollection(t *testing.T) {
@@ -93,6 +275,601 @@ func TestStore_JSONLDCollection(t *testing.T) {
 		_, ok := c.(*collection).documentLoader.(testDocumentLoader)
 		assert.True(t, ok)
 	})
+
+	t.Run("caching documentLoader", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync(), WithCachingDocumentLoader(10, time.Minute), WithDocumentLoader(testDocumentLoader{}))
+
+		c := s.Collection(JSONLDCollection, "test")
+
+		loader, ok := c.(*collection).documentLoader.(*cachingDocumentLoader)
+		if !assert.True(t, ok) {
+			return
+		}
+		_, ok = loader.next.(testDocumentLoader)
+		assert.True(t, ok)
+	})
+
+	t.Run("shorthand", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+
+		c := s.JSONLDCollection("test")
+
+		if !assert.NotNil(t, c) {
+			return
+		}
+
+		assert.Equal(t, JSONLDCollection, c.(*collection).collectionType)
+	})
+}
+
+func TestWithLogger(t *testing.T) {
+	setup := func(t *testing.T) (Store, *bytes.Buffer) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		s, err := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithLogger(logger))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return s, buf
+	}
+	key := NewJSONPath("path.part")
+
+	t.Run("ok - index changes and lookups", func(t *testing.T) {
+		s, buf := setup(t)
+		defer s.Close()
+		c := s.JSONCollection("test")
+
+		_ = c.AddIndex(c.NewIndex("index", NewFieldIndexer(key)))
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		_, _ = c.Find(context.TODO(), New(Eq(key, MustParseScalar("value"))).And(Eq(NewJSONPath("non_indexed"), MustParseScalar("value"))))
+		_ = c.DropIndex("index")
+
+		logged := buf.String()
+		assert.Contains(t, logged, `level=INFO msg="index added" collection=test index=index`)
+		assert.Contains(t, logged, `level=DEBUG msg="index lookup" collection=test index=index query="path.part == value and non_indexed == value"`)
+		assert.Contains(t, logged, `level=DEBUG msg="index seek" collection=test index=index field=path.part terms=1 keys="\"value\""`)
+		assert.Contains(t, logged, `level=DEBUG msg="result scan" collection=test index=index filter="non_indexed == value"`)
+		assert.Contains(t, logged, `level=INFO msg="index dropped" collection=test index=index`)
+	})
+
+	t.Run("ok - full table scan", func(t *testing.T) {
+		s, buf := setup(t)
+		defer s.Close()
+		c := s.JSONCollection("test")
+
+		_, _ = c.Find(context.TODO(), New(Eq(key, MustParseScalar("value"))))
+
+		assert.Contains(t, buf.String(), `level=WARN msg="full table scan, no index matches the query" collection=test query="path.part == value"`)
+	})
+
+	t.Run("ok - scanning all documents isn't logged as full table scan", func(t *testing.T) {
+		s, buf := setup(t)
+		defer s.Close()
+		c := s.JSONCollection("test")
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		_ = c.WalkDocuments(context.TODO(), func(_ Reference, _ []byte) error {
+			return nil
+		})
+		_, _ = c.IterateFrom(New(Eq(key, MustParseScalar("value"))), nil, func(_ Reference, _ []byte) error {
+			return nil
+		})
+		_, _ = c.Distinct(context.TODO(), key)
+
+		assert.NotContains(t, buf.String(), "full table scan")
+	})
+
+	t.Run("ok - vacuum", func(t *testing.T) {
+		s, buf := setup(t)
+		defer s.Close()
+
+		_, _ = s.Vacuum()
+
+		assert.Contains(t, buf.String(), `level=INFO msg="vacuum completed" recovered_bytes=`)
+	})
+
+	t.Run("ok - failing transaction", func(t *testing.T) {
+		s, buf := setup(t)
+		c := s.JSONCollection("test")
+		_ = s.Close()
+
+		err := c.Add(context.TODO(), []Document{exampleDoc})
+
+		assert.ErrorIs(t, err, bbolt.ErrDatabaseNotOpen)
+		assert.Contains(t, buf.String(), `level=ERROR msg="transaction failed" collection=test error="database not open"`)
+	})
+}
+
+func TestWithReadOnly(t *testing.T) {
+	f := filepath.Join(testDirectory(t), "test.db")
+	key := NewJSONPath("path.part")
+	s, _ := NewStore(f, WithoutSync())
+	c := s.JSONCollection("test")
+	_ = c.AddIndex(c.NewIndex("index", NewFieldIndexer(key)))
+	_ = c.Add(context.TODO(), []Document{exampleDoc})
+	_ = s.Close()
+
+	s, err := NewStore(f, WithReadOnly())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	c = s.JSONCollection("test")
+
+	t.Run("ok - stored index is used", func(t *testing.T) {
+		err := c.AddIndex(c.NewIndex("index", NewFieldIndexer(key)))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		result, err := c.Find(context.TODO(), New(Eq(key, MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, result, 1)
+		assert.Equal(t, int64(1), c.CollectionStats().IndexScanCount)
+	})
+
+	t.Run("ok - reads", func(t *testing.T) {
+		doc, found, err := c.Get(c.Reference(exampleDoc))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, found)
+		assert.Equal(t, Document(exampleDoc), doc)
+		count, err := c.DocumentCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error - writes", func(t *testing.T) {
+		assert.ErrorIs(t, c.Add(context.TODO(), []Document{Document(jsonExample2)}), ErrReadOnly)
+		assert.ErrorIs(t, c.Delete(exampleDoc), ErrReadOnly)
+		assert.ErrorIs(t, c.DropIndex("index"), ErrReadOnly)
+		assert.ErrorIs(t, c.AddIndex(c.NewIndex("other", NewFieldIndexer(key))), ErrReadOnly)
+		assert.ErrorIs(t, c.SetMetadata("key", "value"), ErrReadOnly)
+		_, err := s.Vacuum()
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+
+	t.Run("error - stored index with another configuration", func(t *testing.T) {
+		// read-only stores can share the file
+		other, err := NewStore(f, WithReadOnly())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer other.Close()
+		c := other.JSONCollection("test")
+
+		err = c.AddIndex(c.NewIndex("index", NewFieldIndexer(key, TransformerOption(ToLower))))
+
+		assert.ErrorIs(t, err, ErrIndexSchemaMismatch)
+	})
+}
+
+func TestWithStrictBackfill(t *testing.T) {
+	f := filepath.Join(testDirectory(t), "test.db")
+	s, _ := NewStore(f, WithoutSync(), WithStrictBackfill())
+
+	c := s.Collection(JSONCollection, "test")
+
+	assert.True(t, c.(*collection).strictBackfill)
+}
+
+func TestWithAutoRebuildOnMismatch(t *testing.T) {
+	f := filepath.Join(testDirectory(t), "test.db")
+	s, _ := NewStore(f, WithoutSync(), WithAutoRebuildOnMismatch())
+
+	c := s.Collection(JSONCollection, "test")
+
+	assert.True(t, c.(*collection).autoRebuild)
+}
+
+func TestWithDocumentBucketName(t *testing.T) {
+	t.Run("ok - documents are stored in the given bucket", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		c := s.JSONCollection("test", WithDocumentBucketName("_staging"))
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+		docs, err := c.Find(context.Background(), New(Eq(NewJSONPath("path.part"), MustParseScalar("value"))))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, docs, 1)
+		_ = s.(*store).db.View(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket([]byte("test"))
+			assert.NotNil(t, bucket.Bucket([]byte("_staging")))
+			assert.Nil(t, bucket.Bucket([]byte(documentCollection)))
+			return nil
+		})
+	})
+
+	t.Run("error - name without underscore", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+
+		assert.Panics(t, func() {
+			s.JSONCollection("test", WithDocumentBucketName("staging"))
+		})
+	})
+}
+
+func TestWithExactCount(t *testing.T) {
+	s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+
+	c := s.JSONCollection("test", WithExactCount(true))
+
+	assert.True(t, c.(*collection).exactCount)
+}
+
+func TestWithHashFunc(t *testing.T) {
+	f := filepath.Join(testDirectory(t), "test.db")
+	s, _ := NewStore(f, WithoutSync(), WithHashFunc(sha512.New))
+	c := s.JSONCollection("test")
+	_ = c.Add(context.TODO(), []Document{exampleDoc})
+
+	ref := c.Reference(exampleDoc)
+
+	expected := sha512.Sum512(exampleDoc)
+	assert.Equal(t, Reference(expected[:]), ref)
+	doc, _, err := c.Get(ref)
+	assert.NoError(t, err)
+	assert.Equal(t, Document(exampleDoc), doc)
+}
+
+func TestWithReferenceFunc(t *testing.T) {
+	t.Run("ok - SHA-256 references", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithReferenceFunc(SHA256ReferenceCreator))
+		c := s.JSONCollection("test")
+
+		err := c.Add(context.TODO(), []Document{exampleDoc})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		expected := sha256.Sum256(exampleDoc)
+		doc, _, err := c.Get(expected[:])
+		assert.NoError(t, err)
+		assert.Equal(t, Document(exampleDoc), doc)
+	})
+
+	t.Run("error - mixing SHA-1 and SHA-256 references", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		if !assert.NoError(t, s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})) {
+			return
+		}
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync(), WithReferenceFunc(SHA256ReferenceCreator))
+		defer s.Close()
+		c := s.JSONCollection("test")
+
+		err := c.Add(context.TODO(), []Document{[]byte(`{"key": "value"}`)})
+
+		assert.ErrorIs(t, err, ErrReferenceFuncMismatch)
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error - AddTolerant stops on mismatch", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync(), WithReferenceFunc(SHA256ReferenceCreator))
+		_ = s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync())
+		defer s.Close()
+
+		result, err := s.JSONCollection("test").AddTolerant(context.Background(), []Document{[]byte(`{"key": "value"}`)})
+
+		assert.ErrorIs(t, err, ErrReferenceFuncMismatch)
+		assert.Equal(t, 0, result.Added)
+	})
+}
+
+func TestWithFreelistType(t *testing.T) {
+	t.Run("ok - map", func(t *testing.T) {
+		s, err := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithFreelistType(bbolt.FreelistMapType))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Equal(t, bbolt.FreelistMapType, s.(*store).db.db.FreelistType)
+		assert.NoError(t, s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc}))
+	})
+
+	t.Run("error - unknown type", func(t *testing.T) {
+		_, err := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithFreelistType("tree"))
+
+		assert.EqualError(t, err, "unknown freelist type: tree")
+	})
+}
+
+func TestWithInitialMmapSize(t *testing.T) {
+	s, err := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithInitialMmapSize(1<<20))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	assert.Equal(t, 1<<20, s.(*store).options.InitialMmapSize)
+}
+
+func TestWithMaxBatchSize(t *testing.T) {
+	s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithMaxBatchSize(100))
+
+	c := s.JSONCollection("test")
+
+	assert.Equal(t, 100, c.(*collection).maxBatchSize)
+}
+
+func TestWithDocumentSizeLimit(t *testing.T) {
+	t.Run("ok - documents within the limit are added", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithDocumentSizeLimit(len(exampleDoc)))
+		c := s.JSONCollection("test")
+
+		err := c.Add(context.TODO(), []Document{exampleDoc})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error - oversized document, nothing is added", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithDocumentSizeLimit(len(exampleDoc)))
+		c := s.JSONCollection("test")
+		i := c.NewIndex("index", NewFieldIndexer(NewJSONPath("path.part")))
+		_ = c.AddIndex(i)
+		large := Document(fmt.Sprintf(`{"path": {"part": "value"}, "padding": "%s"}`, strings.Repeat("a", len(exampleDoc))))
+
+		err := c.Add(context.TODO(), []Document{exampleDoc, large})
+
+		assert.ErrorIs(t, err, ErrDocumentTooLarge)
+		var sizeErr DocumentSizeError
+		if assert.ErrorAs(t, err, &sizeErr) {
+			assert.Equal(t, len(large), sizeErr.Size)
+			assert.Equal(t, len(exampleDoc), sizeErr.Limit)
+		}
+		count, _ := c.DocumentCount()
+		assert.Equal(t, 0, count)
+		assertIndexSize(t, s.(*store).db.db, i, 0)
+	})
+
+	t.Run("error - reported per document by AddTolerant", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync(), WithDocumentSizeLimit(len(exampleDoc)-1))
+		c := s.JSONCollection("test")
+
+		result, err := c.AddTolerant(context.TODO(), []Document{exampleDoc, []byte(`{}`)})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 1, result.Added)
+		if assert.Len(t, result.Errors, 1) {
+			assert.ErrorIs(t, result.Errors[0], ErrDocumentTooLarge)
+		}
+	})
+}
+
+func TestWithMissingReferencePlaceholders(t *testing.T) {
+	f := filepath.Join(testDirectory(t), "test.db")
+	s, _ := NewStore(f, WithoutSync(), WithMissingReferencePlaceholders())
+
+	c := s.Collection(JSONCollection, "test")
+
+	assert.True(t, c.(*collection).missingPlaceholders)
+}
+
+func TestWithDocumentCompression(t *testing.T) {
+	t.Run("ok - documents are compressed", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		c := s.JSONCollection("test", WithDocumentCompression(LZ4Codec{}))
+		i := c.NewIndex("index", NewFieldIndexer(NewJSONPath("path.part")))
+		_ = c.AddIndex(i)
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		ref := c.Reference(exampleDoc)
+
+		doc, _, err := c.Get(ref)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, Document(exampleDoc), doc)
+		docs, err := c.Find(context.Background(), New(Eq(NewJSONPath("path.part"), MustParseScalar("value"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, docs, 1)
+		_ = s.(*store).db.View(func(tx *bbolt.Tx) error {
+			stored := c.(*collection).documentBucket(tx).Get(ref)
+			assert.Equal(t, lz4Tag, stored[0])
+			return nil
+		})
+	})
+
+	t.Run("ok - mixed codecs", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		_ = s.JSONCollection("test").Add(context.TODO(), []Document{exampleDoc})
+		_ = s.Close()
+		s, _ = NewStore(f, WithoutSync())
+		c := s.JSONCollection("test", WithDocumentCompression(SnappyCodec{}))
+		_ = c.Add(context.TODO(), []Document{[]byte(jsonExample2)})
+		i := c.NewIndex("index", NewFieldIndexer(NewJSONPath("path.part")))
+
+		err := c.AddIndex(i)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		docs, err := c.Find(context.Background(), New(Eq(NewJSONPath("path.part"), MustParseScalar("value"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, docs, 2)
+	})
+
+	t.Run("ok - per collection", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		compressed := s.JSONCollection("compressed", WithDocumentCompression(SnappyCodec{}))
+		plain := s.JSONCollection("plain")
+		_ = compressed.Add(context.TODO(), []Document{exampleDoc})
+		_ = plain.Add(context.TODO(), []Document{exampleDoc})
+		ref := compressed.Reference(exampleDoc)
+
+		_ = s.(*store).db.View(func(tx *bbolt.Tx) error {
+			assert.Equal(t, snappyTag, compressed.(*collection).documentBucket(tx).Get(ref)[0])
+			assert.Equal(t, []byte(exampleDoc), plain.(*collection).documentBucket(tx).Get(ref))
+			return nil
+		})
+	})
+}
+
+func TestStore_CopyCollection(t *testing.T) {
+	t.Run("ok - documents are indexed by the destination", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		src := s.JSONCollection("src")
+		_ = src.Add(context.TODO(), []Document{exampleDoc, []byte(jsonExample2)})
+		dst := s.JSONCollection("dst")
+		i := dst.NewIndex("index", NewFieldIndexer(NewJSONPath("path.parts")))
+		_ = dst.AddIndex(i)
+
+		count, err := s.CopyCollection(context.Background(), "src", "dst", JSONCollection)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2, count)
+		srcCount, _ := src.DocumentCount()
+		assert.Equal(t, 2, srcCount)
+		refs := 0
+		_ = dst.IndexIterate(context.Background(), New(Eq(NewJSONPath("path.parts"), MustParseScalar("value2"))), func(key []byte, value []byte) error {
+			refs++
+			return nil
+		})
+		assert.Equal(t, 1, refs)
+	})
+
+	t.Run("ok - multiple batches", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		docs := make([]Document, copyBatchSize+1)
+		for j := range docs {
+			docs[j] = Document(fmt.Sprintf(`{"id": %d}`, j))
+		}
+		_ = s.JSONCollection("src").Add(context.TODO(), docs)
+
+		count, err := s.CopyCollection(context.Background(), "src", "dst", JSONCollection)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, copyBatchSize+1, count)
+		dstCount, _ := s.JSONCollection("dst").DocumentCount()
+		assert.Equal(t, copyBatchSize+1, dstCount)
+	})
+
+	t.Run("ok - unknown source", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+
+		count, err := s.CopyCollection(context.Background(), "src", "dst", JSONLDCollection)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("error - same collection", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+
+		_, err := s.CopyCollection(context.Background(), "src", "src", JSONCollection)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("error - ctx cancelled", func(t *testing.T) {
+		s, _ := NewStore(filepath.Join(testDirectory(t), "test.db"), WithoutSync())
+		_ = s.JSONCollection("src").Add(context.TODO(), []Document{exampleDoc})
+		ctx, cancelFn := context.WithCancel(context.Background())
+		cancelFn()
+
+		_, err := s.CopyCollection(ctx, "src", "dst", JSONCollection)
+
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestStore_Vacuum(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		f := filepath.Join(testDirectory(t), "test.db")
+		s, _ := NewStore(f, WithoutSync())
+		defer s.Close()
+		c := s.JSONCollection("test")
+		_ = c.AddIndex(c.NewIndex("index", NewFieldIndexer(NewJSONPath("id"))))
+		docs := make([]Document, 1000)
+		for i := range docs {
+			docs[i] = Document(fmt.Sprintf(`{"id": "%d", "data": "%s"}`, i, strings.Repeat("x", 1000)))
+		}
+		_ = c.Add(context.TODO(), docs)
+		_, _ = c.DeleteWhere(context.TODO(), New(Prefix(NewJSONPath("id"), MustParseScalar("1"))))
+
+		recovered, err := s.Vacuum()
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Greater(t, recovered, int64(0))
+		assert.NoFileExists(t, f+".vacuum")
+		// collections use the compacted file
+		result, err := c.Find(context.TODO(), New(Eq(NewJSONPath("id"), MustParseScalar("2"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, result, 1)
+		assert.NoError(t, c.Add(context.TODO(), []Document{exampleDoc}))
+		count, _ := s.JSONCollection("test").Count(context.TODO(), New(Prefix(NewJSONPath("id"), MustParseScalar("1"))))
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("ok - empty store", func(t *testing.T) {
+		s, _ := NewMemStore()
+		defer s.Close()
+
+		_, err := s.Vacuum()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("ok - concurrent reads", func(t *testing.T) {
+		s, _ := NewMemStore()
+		defer s.Close()
+		c, _ := s.OpenCollection(JSONCollection, "test")
+		_ = c.Add(context.TODO(), []Document{exampleDoc})
+		ref := defaultReferenceCreator(exampleDoc)
+		done := make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			defer close(errs)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, _, err := c.Get(ref); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+
+		for i := 0; i < 10; i++ {
+			if _, err := s.Vacuum(); !assert.NoError(t, err) {
+				break
+			}
+		}
+		close(done)
+
+		assert.NoError(t, <-errs)
+	})
 }
 
 type testDocumentLoader struct{}
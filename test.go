@@ -189,7 +189,11 @@ func assertIndexSize(t *testing.T, db *bbolt.DB, i Index, size int) bool {
 		count := 0
 		// loop over sub-buckets
 		cursor := b.Cursor()
-		for k, _ := cursor.Seek([]byte{}); k != nil; k, _ = cursor.Next() {
+		for k, v := cursor.Seek([]byte{}); k != nil; k, v = cursor.Next() {
+			if v != nil {
+				// not a sub-bucket, eg: metadata
+				continue
+			}
 			subBucket := b.Bucket(k)
 			subCursor := subBucket.Cursor()
 			for k2, _ := subCursor.Seek([]byte{}); k2 != nil; k2, _ = subCursor.Next() {
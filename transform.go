@@ -20,8 +20,13 @@
 package leia
 
 import (
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Transform is a function definition for transforming values and search terms.
@@ -37,6 +42,78 @@ func ToLower(scalar Scalar) Scalar {
 	return scalar
 }
 
+// ToUpper transforms all Unicode letters mapped to their upper case.
+// Only StringScalar values are transformed, other values are returned as is.
+func ToUpper(scalar Scalar) Scalar {
+	if s, ok := scalar.(StringScalar); ok {
+		return StringScalar(strings.ToUpper(string(s)))
+	}
+
+	return scalar
+}
+
+// Normalize transforms a string to its Unicode NFC form, so accented characters match regardless of how they were composed.
+// It can be combined with ToLower using ChainTransformer.
+// Only StringScalar values are transformed, other values are returned as is.
+func Normalize(scalar Scalar) Scalar {
+	if s, ok := scalar.(StringScalar); ok {
+		return StringScalar(norm.NFC.String(string(s)))
+	}
+
+	return scalar
+}
+
+// ChainTransformer returns a Transform that applies the given transforms in order, e.g. ChainTransformer(Normalize, ToLower).
+func ChainTransformer(transforms ...Transform) Transform {
+	return func(scalar Scalar) Scalar {
+		for _, transform := range transforms {
+			scalar = transform(scalar)
+		}
+		return scalar
+	}
+}
+
+// EnglishStopWords contains common English words that are rarely useful in a search, to be used with StopWordFilter.
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into", "is", "it", "no", "not",
+	"of", "on", "or", "such", "that", "the", "their", "then", "there", "these", "they", "this", "to", "was", "will", "with",
+}
+
+// StopWordFilter returns a Transform that turns the given words into an empty StringScalar, compared case-insensitively.
+// Empty values resulting from a transform aren't indexed, so combined with a tokenizer the stop words are left out of the index.
+// A query for a stop word doesn't match any document. Other values are returned as is.
+func StopWordFilter(words []string) Transform {
+	stopWords := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		stopWords[strings.ToLower(word)] = struct{}{}
+	}
+	return func(scalar Scalar) Scalar {
+		if s, ok := scalar.(StringScalar); ok {
+			if _, stop := stopWords[strings.ToLower(string(s))]; stop {
+				return StringScalar("")
+			}
+		}
+		return scalar
+	}
+}
+
+// ToInt64 transforms integral Float64Scalar values and StringScalar values containing an integer to an Int64Scalar.
+// JSON integers from 2^53 are collected as Int64Scalar, so they keep their precision. Other values are returned as is.
+func ToInt64(scalar Scalar) Scalar {
+	switch s := scalar.(type) {
+	case Float64Scalar:
+		if f := float64(s); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return Int64Scalar(f)
+		}
+	case StringScalar:
+		if i, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+			return Int64Scalar(i)
+		}
+	}
+
+	return scalar
+}
+
 // Tokenizer is a function definition that transforms a text into tokens
 type Tokenizer func(string) []string
 
@@ -47,3 +124,83 @@ func WhiteSpaceTokenizer(text string) []string {
 	exp, _ := regexp.Compile(nonWhitespaceRegex)
 	return exp.FindAllString(text, -1)
 }
+
+// ChainTokenizer returns a Tokenizer that splits every token of the first tokenizer using the second,
+// e.g. ChainTokenizer(WhiteSpaceTokenizer, NGramTokenizer(3)) returns the trigrams of every word.
+func ChainTokenizer(first Tokenizer, second func(string) []string) Tokenizer {
+	return func(text string) []string {
+		tokens := make([]string, 0)
+		for _, token := range first(text) {
+			tokens = append(tokens, second(token)...)
+		}
+		return tokens
+	}
+}
+
+// NGramTokenizer returns a Tokenizer that splits a text into all substrings of n characters, e.g. "hello" into "hel", "ell" and "llo" for n = 3.
+// Combined with Eq it can be used to find values containing a substring. Texts shorter than n are returned as a single token.
+// It panics if n is smaller than 1.
+func NGramTokenizer(n int) Tokenizer {
+	if n < 1 {
+		panic("n-gram size must be at least 1")
+	}
+	return func(text string) []string {
+		runes := []rune(text)
+		if len(runes) == 0 {
+			return []string{}
+		}
+		if len(runes) <= n {
+			return []string{text}
+		}
+		tokens := make([]string, 0, len(runes)-n+1)
+		for i := 0; i+n <= len(runes); i++ {
+			tokens = append(tokens, string(runes[i:i+n]))
+		}
+		return tokens
+	}
+}
+
+// LimitedTokenizer returns a Tokenizer that returns at most max tokens of the wrapped Tokenizer, the first tokens are kept.
+// Tokens beyond the limit are silently dropped, so a document can't be found by those.
+// It panics if max is smaller than 1.
+func LimitedTokenizer(max int, wrapped Tokenizer) Tokenizer {
+	if max < 1 {
+		panic("token limit must be at least 1")
+	}
+	return func(text string) []string {
+		tokens := wrapped(text)
+		if len(tokens) > max {
+			return tokens[:max]
+		}
+		return tokens
+	}
+}
+
+// LimitedNGramTokenizer returns a NGramTokenizer for n characters that returns at most max tokens, see LimitedTokenizer.
+// It panics if n or max is smaller than 1.
+func LimitedNGramTokenizer(n int, max int) Tokenizer {
+	return LimitedTokenizer(max, NGramTokenizer(n))
+}
+
+// RegexTokenizer returns a Tokenizer that returns all non-overlapping matches of the pattern as tokens,
+// e.g. `\w+` splits a text into words and `[^/]+` splits a path into segments.
+// It panics if the pattern is nil or matches an empty string, since empty tokens can't be indexed.
+func RegexTokenizer(pattern *regexp.Regexp) Tokenizer {
+	if pattern == nil {
+		panic("tokenizer pattern can't be nil")
+	}
+	if pattern.MatchString("") {
+		panic("tokenizer pattern can't match an empty string")
+	}
+	return func(text string) []string {
+		return pattern.FindAllString(text, -1)
+	}
+}
+
+// OrderedWhiteSpaceTokenizer tokenizes the string like WhiteSpaceTokenizer but returns the tokens sorted lexicographically.
+// The order of words in the text doesn't influence the order of the tokens, which is useful for set-like fields.
+func OrderedWhiteSpaceTokenizer(text string) []string {
+	tokens := WhiteSpaceTokenizer(text)
+	sort.Strings(tokens)
+	return tokens
+}
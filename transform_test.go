@@ -20,6 +20,9 @@
 package leia
 
 import (
+	"context"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -66,6 +69,11 @@ func (t testIndexPart) Transform(value Scalar) Scalar {
 	return t.transformer(value)
 }
 
+func (t testIndexPart) EqualsIndexer(other FieldIndexer) bool {
+	o, ok := other.(testIndexPart)
+	return ok && t.path == o.path
+}
+
 func (t testIndexPart) Transformer() Transform {
 	return t.transformer
 }
@@ -103,6 +111,23 @@ func TestIndex_Add(t *testing.T) {
 		assertIndexed(t, db, i, key1, ref)
 		assertIndexed(t, db, i, key2, ref)
 	})
+
+	t.Run("ok - n-grams", func(t *testing.T) {
+		db, c := testCollection(t)
+		i := c.NewIndex("test", testIndexPart{path: "part", tokenizer: NGramTokenizer(3), transformer: ToLower})
+		ref := []byte("01")
+		doc := []byte(`{"part": "Hello"}`)
+
+		err := withinBucket(t, db, func(bucket *bbolt.Bucket) error {
+			return i.Add(bucket, ref, doc)
+		})
+
+		assert.NoError(t, err)
+
+		assertIndexed(t, db, i, []byte("hel"), ref)
+		assertIndexed(t, db, i, []byte("ell"), ref)
+		assertIndexed(t, db, i, []byte("llo"), ref)
+	})
 }
 
 func TestIndex_Iterate(t *testing.T) {
@@ -167,6 +192,158 @@ func TestIndex_Iterate(t *testing.T) {
 	})
 }
 
+func TestToUpper(t *testing.T) {
+	t.Run("ok - string", func(t *testing.T) {
+		assert.Equal(t, StringScalar("ÉCOLE"), ToUpper(StringScalar("école")))
+	})
+
+	t.Run("ok - other value is not transformed", func(t *testing.T) {
+		assert.Equal(t, Float64Scalar(1.5), ToUpper(Float64Scalar(1.5)))
+	})
+
+	t.Run("ok - as transformer option", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex("test", NewFieldIndexer(NewJSONPath("part"), TransformerOption(ToUpper)))
+
+		keys, err := i.Keys(i.Fields()[0], []byte(`{"part": "word"}`))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("WORD")}, keys)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("ok - decomposed string is composed", func(t *testing.T) {
+		// e followed by a combining acute accent
+		assert.Equal(t, StringScalar("école"), Normalize(StringScalar("école")))
+	})
+
+	t.Run("ok - composed string is unchanged", func(t *testing.T) {
+		assert.Equal(t, StringScalar("école"), Normalize(StringScalar("école")))
+	})
+
+	t.Run("ok - other value is not transformed", func(t *testing.T) {
+		assert.Equal(t, Float64Scalar(1.5), Normalize(Float64Scalar(1.5)))
+	})
+}
+
+func TestChainTransformer(t *testing.T) {
+	t.Run("ok - applied in order", func(t *testing.T) {
+		suffix := func(scalar Scalar) Scalar {
+			return StringScalar(scalar.value().(string) + "S")
+		}
+
+		assert.Equal(t, StringScalar("words"), ChainTransformer(suffix, ToLower)(StringScalar("WORD")))
+		assert.Equal(t, StringScalar("wordS"), ChainTransformer(ToLower, suffix)(StringScalar("WORD")))
+	})
+
+	t.Run("ok - no transforms", func(t *testing.T) {
+		assert.Equal(t, StringScalar("WORD"), ChainTransformer()(StringScalar("WORD")))
+	})
+
+	t.Run("ok - with WhiteSpaceTokenizer", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex("test", NewFieldIndexer(NewJSONPath("part"), TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ChainTransformer(Normalize, ToLower))))
+
+		// É decomposed into E followed by a combining acute accent
+		keys, err := i.Keys(i.Fields()[0], []byte(`{"part": "WORD1 E\u0301COLE"}`))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("word1"), StringScalar("école")}, keys)
+	})
+}
+
+func TestStopWordFilter(t *testing.T) {
+	filter := StopWordFilter(EnglishStopWords)
+
+	t.Run("ok - stop word is filtered", func(t *testing.T) {
+		assert.Equal(t, StringScalar(""), filter(StringScalar("the")))
+	})
+
+	t.Run("ok - case-insensitive", func(t *testing.T) {
+		assert.Equal(t, StringScalar(""), filter(StringScalar("The")))
+		assert.Equal(t, StringScalar(""), StopWordFilter([]string{"AND"})(StringScalar("and")))
+	})
+
+	t.Run("ok - other word is not filtered", func(t *testing.T) {
+		assert.Equal(t, StringScalar("word"), filter(StringScalar("word")))
+	})
+
+	t.Run("ok - other value is not filtered", func(t *testing.T) {
+		assert.Equal(t, Float64Scalar(1.5), filter(Float64Scalar(1.5)))
+	})
+
+	t.Run("ok - stop words are not indexed", func(t *testing.T) {
+		db, c := testCollection(t)
+		i := c.NewIndex("test", NewFieldIndexer(NewJSONPath("part"), TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ChainTransformer(ToLower, filter))))
+		ref := []byte("01")
+		doc := []byte(`{"part": "The Lord of the Rings"}`)
+
+		keys, err := i.Keys(i.Fields()[0], doc)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("lord"), StringScalar("rings")}, keys)
+
+		err = withinBucket(t, db, func(bucket *bbolt.Bucket) error {
+			return i.Add(bucket, ref, doc)
+		})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assertIndexed(t, db, i, []byte("lord"), ref)
+		assertIndexed(t, db, i, []byte("rings"), ref)
+		assertIndexSize(t, db, i, 2)
+	})
+
+	t.Run("ok - query for a stop word has no results", func(t *testing.T) {
+		_, c := testCollection(t)
+		i := c.NewIndex("test", NewFieldIndexer(NewJSONPath("part"), TokenizerOption(WhiteSpaceTokenizer), TransformerOption(ChainTransformer(ToLower, filter))))
+		if !assert.NoError(t, c.AddIndex(i)) {
+			return
+		}
+		if !assert.NoError(t, c.Add(context.Background(), []Document{[]byte(`{"part": "The Lord of the Rings"}`)})) {
+			return
+		}
+
+		found, err := c.Find(context.Background(), New(Eq(NewJSONPath("part"), MustParseScalar("rings"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, found, 1)
+
+		found, err = c.Find(context.Background(), New(Eq(NewJSONPath("part"), MustParseScalar("the"))))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, found, 0)
+	})
+}
+
+func TestToInt64(t *testing.T) {
+	t.Run("ok - integral float", func(t *testing.T) {
+		assert.Equal(t, Int64Scalar(-3), ToInt64(Float64Scalar(-3.0)))
+	})
+
+	t.Run("ok - integer string", func(t *testing.T) {
+		assert.Equal(t, Int64Scalar(9007199254740993), ToInt64(StringScalar("9007199254740993")))
+	})
+
+	t.Run("ok - fraction is not transformed", func(t *testing.T) {
+		assert.Equal(t, Float64Scalar(1.5), ToInt64(Float64Scalar(1.5)))
+	})
+
+	t.Run("ok - other string is not transformed", func(t *testing.T) {
+		assert.Equal(t, StringScalar("one"), ToInt64(StringScalar("one")))
+	})
+}
+
 func TestWhiteSpaceTokenizer(t *testing.T) {
 	t.Run("ok - consecutive whitespace", func(t *testing.T) {
 		tokens := WhiteSpaceTokenizer("WORD1 WORD2")
@@ -174,3 +351,139 @@ func TestWhiteSpaceTokenizer(t *testing.T) {
 		assert.Len(t, tokens, 2)
 	})
 }
+
+func TestChainTokenizer(t *testing.T) {
+	t.Run("ok - every token is split", func(t *testing.T) {
+		tokenizer := ChainTokenizer(WhiteSpaceTokenizer, NGramTokenizer(3))
+
+		assert.Equal(t, []string{"abc", "bcd", "xyz"}, tokenizer("abcd  xyz"))
+	})
+
+	t.Run("ok - with ToLower", func(t *testing.T) {
+		_, c := testCollection(t)
+		path := strings.NewReplacer("/", " ")
+		tokenizer := ChainTokenizer(WhiteSpaceTokenizer, func(text string) []string {
+			return WhiteSpaceTokenizer(path.Replace(text))
+		})
+		i := c.NewIndex("test", NewFieldIndexer(NewJSONPath("part"), TokenizerOption(tokenizer), TransformerOption(ToLower)))
+
+		keys, err := i.Keys(i.Fields()[0], []byte(`{"part": "A/B C"}`))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []Scalar{StringScalar("a"), StringScalar("b"), StringScalar("c")}, keys)
+	})
+}
+
+func TestNGramTokenizer(t *testing.T) {
+	t.Run("ok - trigrams", func(t *testing.T) {
+		assert.Equal(t, []string{"hel", "ell", "llo"}, NGramTokenizer(3)("hello"))
+	})
+
+	t.Run("ok - multi-byte characters", func(t *testing.T) {
+		assert.Equal(t, []string{"éc", "co"}, NGramTokenizer(2)("éco"))
+	})
+
+	t.Run("ok - text shorter than n", func(t *testing.T) {
+		assert.Equal(t, []string{"he"}, NGramTokenizer(3)("he"))
+	})
+
+	t.Run("ok - empty text", func(t *testing.T) {
+		assert.Empty(t, NGramTokenizer(3)(""))
+	})
+
+	t.Run("ok - substring is found with Eq", func(t *testing.T) {
+		db, c := testCollection(t)
+		i := c.NewIndex("test", testIndexPart{path: "part", tokenizer: NGramTokenizer(3)})
+		err := withinBucket(t, db, func(bucket *bbolt.Bucket) error {
+			return i.Add(bucket, []byte("01"), []byte(`{"part": "hello"}`))
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		count := 0
+
+		err = withinBucket(t, db, func(bucket *bbolt.Bucket) error {
+			return i.Iterate(bucket, New(Eq(NewJSONPath("part"), MustParseScalar("ell"))), func(key Reference, value []byte) error {
+				count++
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("error - n smaller than 1", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NGramTokenizer(0)
+		})
+	})
+}
+
+func TestLimitedTokenizer(t *testing.T) {
+	t.Run("ok - truncated", func(t *testing.T) {
+		tokens := LimitedTokenizer(2, WhiteSpaceTokenizer)("WORD1 WORD2 WORD3")
+
+		assert.Equal(t, []string{"WORD1", "WORD2"}, tokens)
+	})
+
+	t.Run("ok - less tokens than the limit", func(t *testing.T) {
+		tokens := LimitedTokenizer(5, WhiteSpaceTokenizer)("WORD1 WORD2")
+
+		assert.Equal(t, []string{"WORD1", "WORD2"}, tokens)
+	})
+
+	t.Run("ok - n-grams", func(t *testing.T) {
+		assert.Equal(t, []string{"hel", "ell"}, LimitedNGramTokenizer(3, 2)("hello"))
+	})
+
+	t.Run("error - limit smaller than 1", func(t *testing.T) {
+		assert.Panics(t, func() {
+			LimitedTokenizer(0, WhiteSpaceTokenizer)
+		})
+	})
+}
+
+func TestRegexTokenizer(t *testing.T) {
+	t.Run("ok - words", func(t *testing.T) {
+		tokenizer := RegexTokenizer(regexp.MustCompile(`\w+`))
+
+		assert.Equal(t, []string{"jane", "example", "com"}, tokenizer("jane@example.com"))
+	})
+
+	t.Run("ok - path segments", func(t *testing.T) {
+		tokenizer := RegexTokenizer(regexp.MustCompile(`[^/]+`))
+
+		assert.Equal(t, []string{"api", "v1", "users"}, tokenizer("/api/v1/users/"))
+	})
+
+	t.Run("ok - no matches", func(t *testing.T) {
+		assert.Empty(t, RegexTokenizer(regexp.MustCompile(`\d+`))("text"))
+	})
+
+	t.Run("error - nil pattern", func(t *testing.T) {
+		assert.Panics(t, func() {
+			RegexTokenizer(nil)
+		})
+	})
+
+	t.Run("error - pattern matches empty string", func(t *testing.T) {
+		assert.Panics(t, func() {
+			RegexTokenizer(regexp.MustCompile(`\w*`))
+		})
+	})
+}
+
+func TestOrderedWhiteSpaceTokenizer(t *testing.T) {
+	t.Run("ok - sorted", func(t *testing.T) {
+		tokens := OrderedWhiteSpaceTokenizer("WORD2  WORD3 WORD1")
+
+		assert.Equal(t, []string{"WORD1", "WORD2", "WORD3"}, tokens)
+	})
+
+	t.Run("ok - word order is irrelevant", func(t *testing.T) {
+		assert.Equal(t, OrderedWhiteSpaceTokenizer("b a"), OrderedWhiteSpaceTokenizer("a b"))
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"math"
+	"strconv"
 )
 
 const boltDBFileMode = 0600
@@ -55,6 +56,8 @@ func (r Reference) ByteSize() int {
 type Scalar interface {
 	// Bytes returns the byte value
 	Bytes() []byte
+	// String returns a human-readable representation of the value, e.g. for logging
+	String() string
 	// value helps in testing
 	value() interface{}
 }
@@ -65,6 +68,10 @@ func (ss StringScalar) Bytes() []byte {
 	return []byte(ss)
 }
 
+func (ss StringScalar) String() string {
+	return string(ss)
+}
+
 func (ss StringScalar) value() interface{} {
 	return string(ss)
 }
@@ -78,6 +85,10 @@ func (bs BoolScalar) Bytes() []byte {
 	return []byte{0}
 }
 
+func (bs BoolScalar) String() string {
+	return strconv.FormatBool(bool(bs))
+}
+
 func (bs BoolScalar) value() interface{} {
 	return bool(bs)
 }
@@ -90,18 +101,52 @@ func (fs Float64Scalar) Bytes() []byte {
 	return buf[:]
 }
 
+func (fs Float64Scalar) String() string {
+	return strconv.FormatFloat(float64(fs), 'g', -1, 64)
+}
+
 func (fs Float64Scalar) value() interface{} {
 	return float64(fs)
 }
 
-// bytesScalar is used internally for the NotNil query
-type bytesScalar []byte
+// Int64Scalar is an integer value. It's encoded as big-endian two's complement with the sign bit flipped,
+// so the byte order equals the numeric order, also for values that can't be represented exactly as float64.
+type Int64Scalar int64
+
+// IntScalar creates an Int64Scalar from an int
+func IntScalar(value int) Int64Scalar {
+	return Int64Scalar(value)
+}
+
+func (is Int64Scalar) Bytes() []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(is)^(1<<63))
+	return buf[:]
+}
+
+func (is Int64Scalar) String() string {
+	return strconv.FormatInt(int64(is), 10)
+}
 
-func (bs bytesScalar) Bytes() []byte {
+func (is Int64Scalar) value() interface{} {
+	return int64(is)
+}
+
+// BytesScalar is a binary value, like a DER encoded key or a hash. Its bytes are used as index key as-is,
+// so range and prefix queries follow the byte order of the values.
+// A compound index key is split at KeyDelimiter, so BytesScalar values containing that byte should only be used in the last field of an index.
+type BytesScalar []byte
+
+func (bs BytesScalar) Bytes() []byte {
 	return bs
 }
 
-func (bs bytesScalar) value() interface{} {
+// String returns the value hex encoded
+func (bs BytesScalar) String() string {
+	return hex.EncodeToString(bs)
+}
+
+func (bs BytesScalar) value() interface{} {
 	return bs.Bytes()
 }
 
@@ -115,8 +160,19 @@ func ParseScalar(value interface{}) (Scalar, error) {
 		return BoolScalar(castValue), nil
 	case string:
 		return StringScalar(castValue), nil
+	case []byte:
+		return BytesScalar(castValue), nil
 	case float64:
 		return Float64Scalar(castValue), nil
+	case int:
+		return Int64Scalar(castValue), nil
+	case int64:
+		return Int64Scalar(castValue), nil
+	case uint64:
+		if castValue > math.MaxInt64 {
+			return nil, ErrInvalidValue
+		}
+		return Int64Scalar(castValue), nil
 	}
 
 	return nil, ErrInvalidValue
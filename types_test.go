@@ -20,7 +20,9 @@
 package leia
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math"
 	"testing"
 
@@ -114,6 +116,49 @@ func TestParseScalar(t *testing.T) {
 		assert.Equal(t, false, s.value())
 	})
 
+	t.Run("ok - int", func(t *testing.T) {
+		s, err := ParseScalar(1)
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, Int64Scalar(1), s)
+	})
+
+	t.Run("ok - int64", func(t *testing.T) {
+		s, err := ParseScalar(int64(-1))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, int64(-1), s.value())
+	})
+
+	t.Run("ok - uint64", func(t *testing.T) {
+		s, err := ParseScalar(uint64(math.MaxInt64))
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, Int64Scalar(math.MaxInt64), s)
+	})
+
+	t.Run("ok - bytes", func(t *testing.T) {
+		s, err := ParseScalar([]byte{0x01, 0x10})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, BytesScalar{0x01, 0x10}, s)
+		assert.Equal(t, []byte{0x01, 0x10}, s.value())
+	})
+
+	t.Run("err - uint64 overflow", func(t *testing.T) {
+		_, err := ParseScalar(uint64(math.MaxUint64))
+
+		assert.Equal(t, ErrInvalidValue, err)
+	})
+
 	t.Run("err - unsupported", func(t *testing.T) {
 		_, err := ParseScalar(struct{}{})
 
@@ -121,6 +166,35 @@ func TestParseScalar(t *testing.T) {
 	})
 }
 
+func TestScalar_String(t *testing.T) {
+	t.Run("ok - string", func(t *testing.T) {
+		assert.Equal(t, "string", StringScalar("string").String())
+	})
+
+	t.Run("ok - bool", func(t *testing.T) {
+		assert.Equal(t, "true", BoolScalar(true).String())
+		assert.Equal(t, "false", BoolScalar(false).String())
+	})
+
+	t.Run("ok - number", func(t *testing.T) {
+		assert.Equal(t, "1", Float64Scalar(1.0).String())
+		assert.Equal(t, "-1.5", Float64Scalar(-1.5).String())
+		assert.Equal(t, "1e+21", Float64Scalar(1e21).String())
+	})
+
+	t.Run("ok - int64", func(t *testing.T) {
+		assert.Equal(t, "-9007199254740993", Int64Scalar(-9007199254740993).String())
+	})
+
+	t.Run("ok - bytes", func(t *testing.T) {
+		assert.Equal(t, "00ff10", BytesScalar{0x00, 0xff, 0x10}.String())
+	})
+
+	t.Run("ok - format verbs", func(t *testing.T) {
+		assert.Equal(t, "value 1.5 00ff", fmt.Sprintf("%s %v %s", StringScalar("value"), Float64Scalar(1.5), BytesScalar{0x00, 0xff}))
+	})
+}
+
 func TestScalar_Bytes(t *testing.T) {
 	t.Run("ok - string", func(t *testing.T) {
 		s := StringScalar("string")
@@ -146,6 +220,23 @@ func TestScalar_Bytes(t *testing.T) {
 		assert.Equal(t, []byte{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}, s.Bytes())
 	})
 
+	t.Run("ok - int64", func(t *testing.T) {
+		assert.Equal(t, []byte{0x80, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1}, IntScalar(1).Bytes())
+		assert.Equal(t, []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, IntScalar(-1).Bytes())
+	})
+
+	t.Run("ok - int64 byte order equals numeric order", func(t *testing.T) {
+		values := []Int64Scalar{math.MinInt64, -1 << 53, -1, 0, 1, 1<<53 + 1, 1<<53 + 2, math.MaxInt64}
+
+		for j := 1; j < len(values); j++ {
+			assert.Equal(t, -1, bytes.Compare(values[j-1].Bytes(), values[j].Bytes()), "%d < %d", values[j-1], values[j])
+		}
+	})
+
+	t.Run("ok - bytes", func(t *testing.T) {
+		assert.Equal(t, []byte{0x00, 0xff}, BytesScalar{0x00, 0xff}.Bytes())
+	})
+
 	t.Run("ok - true", func(t *testing.T) {
 		s := BoolScalar(true)
 
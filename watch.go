@@ -0,0 +1,128 @@
+/*
+ * go-leia
+ * Copyright (C) 2022 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package leia
+
+import (
+	"context"
+	"sync"
+)
+
+// DocumentOp defines the operation of a DocumentEvent
+type DocumentOp int
+
+const (
+	// OpAdd is used when a document has been added
+	OpAdd DocumentOp = iota
+	// OpDelete is used when a document has been deleted
+	OpDelete
+)
+
+// watchBufferSize is the number of events that are buffered for a subscriber before writers are blocked
+const watchBufferSize = 64
+
+// DocumentEvent is sent to the subscribers of a collection when a document has been added or deleted
+type DocumentEvent struct {
+	// Op is the operation that was performed
+	Op DocumentOp
+	// Reference is the reference of the document
+	Reference Reference
+	// Document contains the raw bytes of the document
+	Document Document
+}
+
+// subscriber is a channel created by Watch, done is closed when the context of the subscriber is done
+type subscriber struct {
+	events chan DocumentEvent
+	done   <-chan struct{}
+}
+
+// watchers holds the subscribers of a collection
+type watchers struct {
+	mutex       sync.RWMutex
+	subscribers []*subscriber
+}
+
+// subscribe adds a subscriber, which is removed and closed when the context is done
+func (w *watchers) subscribe(ctx context.Context) <-chan DocumentEvent {
+	s := &subscriber{
+		events: make(chan DocumentEvent, watchBufferSize),
+		done:   ctx.Done(),
+	}
+
+	w.mutex.Lock()
+	w.subscribers = append(w.subscribers, s)
+	w.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		for j, other := range w.subscribers {
+			if other == s {
+				w.subscribers = append(w.subscribers[:j], w.subscribers[j+1:]...)
+				break
+			}
+		}
+		close(s.events)
+	}()
+
+	return s.events
+}
+
+// pendingEvents collects the documents of a write operation, so the subscribers can be notified after the write released its locks.
+// A subscriber may query the collection on an event, notifying it while holding the lock on the indices could deadlock.
+type pendingEvents struct {
+	batches []pendingBatch
+}
+
+type pendingBatch struct {
+	op   DocumentOp
+	docs []Document
+}
+
+// add queues an event for each document
+func (p *pendingEvents) add(op DocumentOp, docs ...Document) {
+	if len(docs) > 0 {
+		p.batches = append(p.batches, pendingBatch{op: op, docs: docs})
+	}
+}
+
+// notify sends the pending events to all subscribers.
+// It blocks until each subscriber has received the events or its context is done, so it must not be called while holding a lock.
+func (w *watchers) notify(pending *pendingEvents, refMake ReferenceFunc) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if len(w.subscribers) == 0 {
+		return
+	}
+	for _, batch := range pending.batches {
+		for _, doc := range batch.docs {
+			event := DocumentEvent{Op: batch.op, Reference: refMake(doc), Document: doc}
+			for _, s := range w.subscribers {
+				select {
+				case s.events <- event:
+				case <-s.done:
+				}
+			}
+		}
+	}
+}